@@ -12,8 +12,18 @@ import (
 )
 
 const (
-	groupAddEndpointURL  = "/api/group/add"
-	groupListEndpointURL = "/api/group/list"
+	groupAddEndpointURL    = "/api/group/add"
+	groupListEndpointURL   = "/api/group/list"
+	groupUpdateEndpointURL = "/api/group/update"
+	groupScaleEndpointURL  = "/api/group/scale"
+	groupStartEndpointURL  = "/api/group/start"
+	groupStopEndpointURL   = "/api/group/stop"
+	groupRemoveEndpointURL = "/api/group/remove"
+
+	reconcileStartEndpointURL  = "/api/reconcile/start"
+	reconcileStatusEndpointURL = "/api/reconcile/status"
+
+	statusEndpointURL = "/api/status"
 )
 
 var (
@@ -36,13 +46,57 @@ func NewClient(url string) *Client {
 	}
 }
 
-func (c *Client) AddGroup(groupID string, subscription string, bootstrapBrokers string) error {
-	_, err := c.get(groupAddEndpointURL, map[string]interface{}{
-		framework.ParamGroupID:          groupID,
-		framework.ParamSubscription:     subscription,
-		framework.ParamBootstrapBrokers: bootstrapBrokers,
-	})
+// AddGroup adds a given group to the framework. Zero values are omitted so the framework defaults apply, unless
+// their parameters, e.g. framework.ParamDisk, are given as explicitly set.
+func (c *Client) AddGroup(group *framework.Group, explicit ...string) error {
+	params := map[string]interface{}{
+		framework.ParamGroupID:          group.ID,
+		framework.ParamSubscription:     strings.Join(group.Subscriptions, ","),
+		framework.ParamBootstrapBrokers: strings.Join(group.BootstrapBrokers, ","),
+	}
+
+	explicitParams := make(map[string]bool)
+	for _, param := range explicit {
+		explicitParams[param] = true
+	}
+
+	if group.Cpus != 0 || explicitParams[framework.ParamCpus] {
+		params[framework.ParamCpus] = group.Cpus
+	}
+
+	if group.Mem != 0 || explicitParams[framework.ParamMem] {
+		params[framework.ParamMem] = group.Mem
+	}
+
+	if group.Disk != 0 || explicitParams[framework.ParamDisk] {
+		params[framework.ParamDisk] = group.Disk
+	}
 
+	if len(group.Options) != 0 {
+		params[framework.ParamOptions] = framework.FormatOptions(group.Options)
+	}
+
+	if group.Instances != 0 || explicitParams[framework.ParamInstances] {
+		params[framework.ParamInstances] = group.Instances
+	}
+
+	if group.Ports != 0 {
+		params[framework.ParamPorts] = group.Ports
+	}
+
+	if len(group.Constraints) != 0 {
+		params[framework.ParamConstraints] = framework.FormatConstraints(group.Constraints)
+	}
+
+	if group.UnreachableTimeout != 0 {
+		params[framework.ParamUnreachableTimeout] = group.UnreachableTimeout
+	}
+
+	if group.KillGracePeriod != 0 {
+		params[framework.ParamKillGracePeriod] = group.KillGracePeriod
+	}
+
+	_, err := c.get(groupAddEndpointURL, params)
 	return err
 }
 
@@ -61,6 +115,124 @@ func (c *Client) ListGroups() ([]*framework.Group, error) {
 	return groups, nil
 }
 
+// UpdateGroup sends a partial group configuration change. Only non-nil fields of a given update are sent.
+func (c *Client) UpdateGroup(groupID string, update *framework.GroupUpdate) error {
+	params := map[string]interface{}{
+		framework.ParamGroupID: groupID,
+	}
+
+	if update.Subscriptions != nil {
+		params[framework.ParamSubscription] = strings.Join(update.Subscriptions, ",")
+	}
+
+	if update.BootstrapBrokers != nil {
+		params[framework.ParamBootstrapBrokers] = strings.Join(update.BootstrapBrokers, ",")
+	}
+
+	if update.Cpus != nil {
+		params[framework.ParamCpus] = *update.Cpus
+	}
+
+	if update.Mem != nil {
+		params[framework.ParamMem] = *update.Mem
+	}
+
+	if update.Disk != nil {
+		params[framework.ParamDisk] = *update.Disk
+	}
+
+	if update.Options != nil {
+		params[framework.ParamOptions] = framework.FormatOptions(update.Options)
+	}
+
+	if update.UnreachableTimeout != nil {
+		params[framework.ParamUnreachableTimeout] = *update.UnreachableTimeout
+	}
+
+	if update.KillGracePeriod != nil {
+		params[framework.ParamKillGracePeriod] = *update.KillGracePeriod
+	}
+
+	_, err := c.get(groupUpdateEndpointURL, params)
+	return err
+}
+
+// ScaleGroup changes the number of consumers running in a given group.
+func (c *Client) ScaleGroup(groupID string, instances int) error {
+	_, err := c.get(groupScaleEndpointURL, map[string]interface{}{
+		framework.ParamGroupID:   groupID,
+		framework.ParamInstances: instances,
+	})
+
+	return err
+}
+
+func (c *Client) StartGroup(groupID string) error {
+	_, err := c.get(groupStartEndpointURL, map[string]interface{}{
+		framework.ParamGroupID: groupID,
+	})
+
+	return err
+}
+
+func (c *Client) StopGroup(groupID string) error {
+	_, err := c.get(groupStopEndpointURL, map[string]interface{}{
+		framework.ParamGroupID: groupID,
+	})
+
+	return err
+}
+
+// RemoveGroup removes a stopped group. If force is set, the group is stopped before removing.
+func (c *Client) RemoveGroup(groupID string, force bool) error {
+	_, err := c.get(groupRemoveEndpointURL, map[string]interface{}{
+		framework.ParamGroupID: groupID,
+		framework.ParamForce:   force,
+	})
+
+	return err
+}
+
+// Reconcile triggers explicit reconciliation of all known tasks and returns its progress.
+func (c *Client) Reconcile() (*framework.ReconcileStatus, error) {
+	return c.reconcileStatus(reconcileStartEndpointURL)
+}
+
+func (c *Client) ReconcileStatus() (*framework.ReconcileStatus, error) {
+	return c.reconcileStatus(reconcileStatusEndpointURL)
+}
+
+func (c *Client) reconcileStatus(endpoint string) (*framework.ReconcileStatus, error) {
+	rawStatus, err := c.get(endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	status := new(framework.ReconcileStatus)
+	err = json.Unmarshal(rawStatus, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// Status returns registration status of the framework along with the currently leading Mesos master.
+func (c *Client) Status() (*framework.FrameworkStatus, error) {
+	rawStatus, err := c.get(statusEndpointURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	status := new(framework.FrameworkStatus)
+	err = json.Unmarshal(rawStatus, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
 func (c *Client) get(endpoint string, params map[string]interface{}) ([]byte, error) {
 	values := url.Values{}
 	for key, value := range params {
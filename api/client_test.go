@@ -3,10 +3,12 @@ package api
 import (
 	"bytes"
 	"errors"
+	"github.com/serejja/gonsumer-mesos/framework"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
 	"net/http"
 	"testing"
+	"time"
 )
 
 type mockHttpClient struct {
@@ -87,6 +89,10 @@ func TestClientAddGroup(t *testing.T) {
 			assert.Contains(t, url, "bootstrap-brokers=localhost%3A9092")
 			assert.Contains(t, url, "group-id=foo")
 			assert.Contains(t, url, "subscription=bar")
+			assert.Contains(t, url, "cpus=0.2")
+			assert.Contains(t, url, "mem=512")
+			assert.NotContains(t, url, "disk")
+			assert.Contains(t, url, "constraints=hostname%3Dunique")
 
 			return &http.Response{
 				StatusCode: 200,
@@ -95,7 +101,34 @@ func TestClientAddGroup(t *testing.T) {
 		},
 	}
 
-	err := client.AddGroup("foo", "bar", "localhost:9092")
+	err := client.AddGroup(&framework.Group{
+		ID:               "foo",
+		Subscriptions:    []string{"bar"},
+		BootstrapBrokers: []string{"localhost:9092"},
+		Cpus:             0.2,
+		Mem:              512,
+		Constraints:      []*framework.Constraint{{Attribute: "hostname", Operator: framework.ConstraintUnique}},
+	})
+	assert.Nil(t, err)
+}
+
+func TestClientAddGroupExplicitZero(t *testing.T) {
+	client := NewClient("endpoint")
+	client.httpClient = mockHttpClient{
+		GetFunc: func(url string) (*http.Response, error) {
+			assert.Contains(t, url, "disk=0")
+			assert.Contains(t, url, "instances=0")
+			assert.NotContains(t, url, "cpus")
+			assert.NotContains(t, url, "mem")
+
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte{})),
+			}, nil
+		},
+	}
+
+	err := client.AddGroup(&framework.Group{ID: "foo"}, framework.ParamDisk, framework.ParamInstances)
 	assert.Nil(t, err)
 }
 
@@ -145,8 +178,127 @@ func TestClientListGroups(t *testing.T) {
 	assert.Nil(t, groups)
 }
 
+func TestClientUpdateGroup(t *testing.T) {
+	client := NewClient("endpoint")
+	client.httpClient = mockHttpClient{
+		GetFunc: func(url string) (*http.Response, error) {
+			assert.Contains(t, url, "endpoint/api/group/update?")
+			assert.Contains(t, url, "group-id=foo")
+			assert.Contains(t, url, "mem=512")
+			assert.Contains(t, url, "options=a%3D1%2Cb%3D2")
+			assert.Contains(t, url, "unreachable-timeout=10m0s")
+			assert.Contains(t, url, "kill-grace-period=1m0s")
+			assert.NotContains(t, url, "cpus")
+			assert.NotContains(t, url, "subscription")
+
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte{})),
+			}, nil
+		},
+	}
+
+	mem := 512.0
+	unreachableTimeout := 10 * time.Minute
+	killGracePeriod := time.Minute
+	err := client.UpdateGroup("foo", &framework.GroupUpdate{
+		Mem:                &mem,
+		Options:            map[string]string{"a": "1", "b": "2"},
+		UnreachableTimeout: &unreachableTimeout,
+		KillGracePeriod:    &killGracePeriod,
+	})
+	assert.Nil(t, err)
+}
+
+func TestClientGroupOperations(t *testing.T) {
+	var requestedURL string
+	client := NewClient("endpoint")
+	client.httpClient = mockHttpClient{
+		GetFunc: func(url string) (*http.Response, error) {
+			requestedURL = url
+
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte{})),
+			}, nil
+		},
+	}
+
+	err := client.StartGroup("foo")
+	assert.Nil(t, err)
+	assert.Contains(t, requestedURL, "endpoint/api/group/start?group-id=foo")
+
+	err = client.StopGroup("foo")
+	assert.Nil(t, err)
+	assert.Contains(t, requestedURL, "endpoint/api/group/stop?group-id=foo")
+
+	err = client.ScaleGroup("foo", 0)
+	assert.Nil(t, err)
+	assert.Contains(t, requestedURL, "endpoint/api/group/scale?")
+	assert.Contains(t, requestedURL, "instances=0")
+
+	err = client.RemoveGroup("foo", true)
+	assert.Nil(t, err)
+	assert.Contains(t, requestedURL, "endpoint/api/group/remove?")
+	assert.Contains(t, requestedURL, "force=true")
+	assert.Contains(t, requestedURL, "group-id=foo")
+
+	client.httpClient = mockHttpClient{
+		GetFunc: func(url string) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 400,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"error":"Group should be stopped before removing"}`))),
+			}, nil
+		},
+	}
+
+	err = client.RemoveGroup("foo", false)
+	assert.EqualError(t, err, framework.ErrGroupNotStopped.Error())
+}
+
 type brokenReader struct{}
 
 func (r *brokenReader) Read(p []byte) (n int, err error) {
 	return 0, errors.New("read error!")
 }
+
+func TestClientReconcile(t *testing.T) {
+	client := NewClient("endpoint")
+	client.httpClient = mockHttpClient{
+		GetFunc: func(url string) (*http.Response, error) {
+			assert.Contains(t, url, "endpoint/api/reconcile/start")
+
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"tries":1,"max_tries":3,"pending_tasks":["foo"]}`))),
+			}, nil
+		},
+	}
+
+	status, err := client.Reconcile()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, status.Tries)
+	assert.Equal(t, 3, status.MaxTries)
+	assert.Equal(t, []string{"foo"}, status.PendingTasks)
+}
+
+func TestClientStatus(t *testing.T) {
+	client := NewClient("endpoint")
+	client.httpClient = mockHttpClient{
+		GetFunc: func(url string) (*http.Response, error) {
+			assert.Contains(t, url, "endpoint/api/status")
+
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"framework_id":"foo","registered":true,"master":"zk://zk:2181/mesos","leading_master":"master:5050"}`))),
+			}, nil
+		},
+	}
+
+	status, err := client.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", status.FrameworkID)
+	assert.True(t, status.Registered)
+	assert.Equal(t, "zk://zk:2181/mesos", status.Master)
+	assert.Equal(t, "master:5050", status.LeadingMaster)
+}
@@ -38,7 +38,7 @@ func main() {
 				apiFlag,
 				cli.StringFlag{
 					Name:  cmd.FrameworkMasterFlag,
-					Usage: "Mesos Master address in form <ip>:<port>.",
+					Usage: "Mesos Master address in form <ip>:<port> or zk://<host>:<port>[,<host>:<port>]/<path>.",
 					Value: framework.DefaultFrameworkMaster,
 				},
 				cli.StringFlag{
@@ -58,7 +58,7 @@ func main() {
 				},
 				cli.StringFlag{
 					Name:  cmd.FrameworkStorageFlag,
-					Usage: "Storage for cluster state.",
+					Usage: "Storage for cluster state: file:<path>, zk:<host>:<port>/<path>, etcd:<host>:<port>[,<host>:<port>...][/<prefix>][?cert=<file>&key=<file>&ca=<file>], consul:<host>:<port>[/<prefix>][?token=<token>&dc=<datacenter>] or mem:<name>.",
 					Value: framework.DefaultFrameworkStorage,
 				},
 				cli.StringFlag{
@@ -69,9 +69,88 @@ func main() {
 					Name:  cmd.FrameworkBindIPFlag,
 					Usage: "Scheduler driver binding IP address. Optional.",
 				},
+				cli.StringFlag{
+					Name:  cmd.FrameworkSchedulerAPIFlag,
+					Usage: "Mesos scheduler API to use: driver (libprocess, requires master to reach --bind-ip) or http (v1 HTTP API, works behind NAT).",
+					Value: framework.DefaultSchedulerAPI,
+				},
+				cli.StringFlag{
+					Name:  cmd.FrameworkPrincipalFlag,
+					Usage: "Principal to authenticate the framework with Mesos master. Optional.",
+				},
+				cli.StringFlag{
+					Name:  cmd.FrameworkSecretFileFlag,
+					Usage: "File containing the secret of the principal used for SASL CRAM-MD5 authentication. Optional.",
+				},
+				cli.Float64Flag{
+					Name:  cmd.FrameworkConsumerCpusFlag,
+					Usage: "Default amount of CPUs for each consumer task.",
+					Value: framework.DefaultConsumerCpus,
+				},
+				cli.Float64Flag{
+					Name:  cmd.FrameworkConsumerMemFlag,
+					Usage: "Default amount of memory (in MB) for each consumer task.",
+					Value: framework.DefaultConsumerMem,
+				},
+				cli.Float64Flag{
+					Name:  cmd.FrameworkConsumerDiskFlag,
+					Usage: "Default amount of disk (in MB) for each consumer task.",
+					Value: framework.DefaultConsumerDisk,
+				},
+				cli.DurationFlag{
+					Name:  cmd.FrameworkUnreachableTimeoutFlag,
+					Usage: "Default time to wait for a consumer on a partitioned agent to come back before launching a replacement.",
+					Value: framework.DefaultUnreachableTimeout,
+				},
+				cli.DurationFlag{
+					Name:  cmd.FrameworkKillGracePeriodFlag,
+					Usage: "Default time a killed consumer gets to commit offsets and leave its consumer group before it is killed forcefully.",
+					Value: framework.DefaultKillGracePeriod,
+				},
+				cli.BoolFlag{
+					Name:  cmd.FrameworkLeaderElectionFlag,
+					Usage: "Elect a leader among several framework instances. Standby instances redirect API requests to the leader.",
+				},
+				cli.StringFlag{
+					Name:  cmd.FrameworkLeaderZkFlag,
+					Usage: "ZooKeeper address in form <host>:<port>/<path> used for leader election. Defaults to zk: storage address.",
+				},
+				cli.Float64Flag{
+					Name:  cmd.FrameworkRefuseSecondsFlag,
+					Usage: "How long (in seconds) declined offers should not be offered again.",
+					Value: framework.DefaultRefuseSeconds,
+				},
+				cli.DurationFlag{
+					Name:  cmd.FrameworkRestartBackoffFlag,
+					Usage: "Delay before relaunching a failed consumer. Doubles with each consecutive failure.",
+					Value: framework.DefaultRestartBackoff,
+				},
+				cli.DurationFlag{
+					Name:  cmd.FrameworkRestartBackoffMaxFlag,
+					Usage: "Maximum delay before relaunching a failed consumer.",
+					Value: framework.DefaultRestartBackoffMax,
+				},
+				cli.DurationFlag{
+					Name:  cmd.FrameworkRestartResetAfterFlag,
+					Usage: "How long a consumer should stay running for its failure count to be reset.",
+					Value: framework.DefaultRestartResetAfter,
+				},
+				cli.StringFlag{
+					Name:  cmd.FrameworkExecutorFlag,
+					Usage: "Path to gonsumer-mesos binary served to executors. Defaults to the running binary.",
+				},
+				cli.StringFlag{
+					Name:  cmd.FrameworkArtifactsFlag,
+					Usage: "Comma separated list of extra files served to executors along with the binary.",
+				},
 			},
 			Action: cmd.FrameworkAction,
 		},
+		{
+			Name:   "executor",
+			Usage:  "Launch Mesos executor. Used by the framework to run consumer tasks",
+			Action: cmd.ExecutorAction,
+		},
 		{
 			Name:  "group",
 			Usage: "Manage consumer groups",
@@ -83,17 +162,27 @@ func main() {
 					Action:   cmd.GroupAddAction,
 					Flags: []cli.Flag{
 						apiFlag,
-						cli.StringFlag{
-							Name:  cmd.GroupIDFlag,
-							Usage: "Group ID to identify a set of consumers. Required.",
+						groupIDFlag,
+						groupSubscriptionFlag,
+						groupBootstrapBrokersFlag,
+						groupCpusFlag,
+						groupMemFlag,
+						groupDiskFlag,
+						groupOptionsFlag,
+						groupUnreachableTimeoutFlag,
+						groupKillGracePeriodFlag,
+						cli.IntFlag{
+							Name:  cmd.GroupInstancesFlag,
+							Usage: "Number of consumers to run.",
+							Value: 1,
 						},
-						cli.StringFlag{
-							Name:  cmd.GroupSubscriptionFlag,
-							Usage: "Group subscription expression.",
+						cli.IntFlag{
+							Name:  cmd.GroupPortsFlag,
+							Usage: "Number of ports to allocate for each consumer. Passed to consumers as PORT0..PORTn and PORTS environment variables.",
 						},
 						cli.StringFlag{
-							Name:  cmd.GroupBootstrapBrokersFlag,
-							Usage: "Group bootstrap Kafka brokers to discover cluster.",
+							Name:  cmd.GroupConstraintsFlag,
+							Usage: "Comma separated placement constraints in form attribute=operator[:value], e.g. hostname=unique,rack=like:r1.*. Supported operators: unique, cluster, like, unlike, groupBy.",
 						},
 					},
 				},
@@ -102,24 +191,66 @@ func main() {
 					Name:     "update",
 					Usage:    "Update consumer group configuration",
 					Action:   cmd.GroupUpdateAction,
+					Flags: []cli.Flag{
+						apiFlag,
+						groupIDFlag,
+						groupSubscriptionFlag,
+						groupBootstrapBrokersFlag,
+						groupCpusFlag,
+						groupMemFlag,
+						groupDiskFlag,
+						groupOptionsFlag,
+						groupUnreachableTimeoutFlag,
+						groupKillGracePeriodFlag,
+					},
+				},
+				{
+					Category: "group",
+					Name:     "scale",
+					Usage:    "Scale consumer group to a given number of consumers",
+					Action:   cmd.GroupScaleAction,
+					Flags: []cli.Flag{
+						apiFlag,
+						groupIDFlag,
+						cli.IntFlag{
+							Name:  cmd.GroupInstancesFlag,
+							Usage: "Number of consumers to run. Zero is allowed. Required.",
+						},
+					},
 				},
 				{
 					Category: "group",
 					Name:     "start",
 					Usage:    "Start consumer group",
 					Action:   cmd.GroupStartAction,
+					Flags: []cli.Flag{
+						apiFlag,
+						groupIDFlag,
+					},
 				},
 				{
 					Category: "group",
 					Name:     "stop",
 					Usage:    "Stop consumer group",
 					Action:   cmd.GroupStopAction,
+					Flags: []cli.Flag{
+						apiFlag,
+						groupIDFlag,
+					},
 				},
 				{
 					Category: "group",
 					Name:     "remove",
 					Usage:    "Remove consumer group",
 					Action:   cmd.GroupRemoveAction,
+					Flags: []cli.Flag{
+						apiFlag,
+						groupIDFlag,
+						cli.BoolFlag{
+							Name:  cmd.GroupForceFlag,
+							Usage: "Stop the group before removing if it is running.",
+						},
+					},
 				},
 				{
 					Category: "group",
@@ -132,6 +263,38 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "status",
+			Usage:  "Show framework registration status and leading Mesos master",
+			Action: cmd.StatusAction,
+			Flags: []cli.Flag{
+				apiFlag,
+			},
+		},
+		{
+			Name:  "reconcile",
+			Usage: "Reconcile task states with Mesos master",
+			Subcommands: []cli.Command{
+				{
+					Category: "reconcile",
+					Name:     "start",
+					Usage:    "Start explicit reconciliation of all known tasks",
+					Action:   cmd.ReconcileStartAction,
+					Flags: []cli.Flag{
+						apiFlag,
+					},
+				},
+				{
+					Category: "reconcile",
+					Name:     "status",
+					Usage:    "Show reconciliation progress",
+					Action:   cmd.ReconcileStatusAction,
+					Flags: []cli.Flag{
+						apiFlag,
+					},
+				},
+			},
+		},
 	}
 
 	app.CommandNotFound = func(c *cli.Context, command string) {
@@ -153,3 +316,48 @@ var apiFlag = cli.StringFlag{
 	Name:  cmd.ApiFlag,
 	Usage: "host:port address for gonsumer-mesos API server. Required.",
 }
+
+var groupIDFlag = cli.StringFlag{
+	Name:  cmd.GroupIDFlag,
+	Usage: "Group ID to identify a set of consumers. Required.",
+}
+
+var groupSubscriptionFlag = cli.StringFlag{
+	Name:  cmd.GroupSubscriptionFlag,
+	Usage: "Group subscription expression.",
+}
+
+var groupBootstrapBrokersFlag = cli.StringFlag{
+	Name:  cmd.GroupBootstrapBrokersFlag,
+	Usage: "Group bootstrap Kafka brokers to discover cluster.",
+}
+
+var groupCpusFlag = cli.Float64Flag{
+	Name:  cmd.GroupCpusFlag,
+	Usage: "Amount of CPUs for each consumer task. Defaults to framework --consumer-cpus.",
+}
+
+var groupMemFlag = cli.Float64Flag{
+	Name:  cmd.GroupMemFlag,
+	Usage: "Amount of memory (in MB) for each consumer task. Defaults to framework --consumer-mem.",
+}
+
+var groupDiskFlag = cli.Float64Flag{
+	Name:  cmd.GroupDiskFlag,
+	Usage: "Amount of disk (in MB) for each consumer task. Defaults to framework --consumer-disk.",
+}
+
+var groupUnreachableTimeoutFlag = cli.DurationFlag{
+	Name:  cmd.GroupUnreachableTimeoutFlag,
+	Usage: "Time to wait for a consumer on a partitioned agent to come back before launching a replacement. Defaults to framework --unreachable-timeout.",
+}
+
+var groupKillGracePeriodFlag = cli.DurationFlag{
+	Name:  cmd.GroupKillGracePeriodFlag,
+	Usage: "Time a killed consumer gets to commit offsets and leave the consumer group before it is killed forcefully. Defaults to framework --kill-grace-period.",
+}
+
+var groupOptionsFlag = cli.StringFlag{
+	Name:  cmd.GroupOptionsFlag,
+	Usage: "Gonsumer consumer options in form key1=value1,key2=value2.",
+}
@@ -5,3 +5,5 @@ import "errors"
 var ErrApiRequired = errors.New("Unspecified gonsumer-mesos API server address. Use --api flag or GM_API env to set.")
 
 var ErrGroupIDRequired = errors.New("Group --id flag is required.")
+
+var ErrInstancesRequired = errors.New("Group --instances flag is required.")
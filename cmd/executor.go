@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	mesosexec "github.com/mesos/mesos-go/executor"
+	"github.com/serejja/gonsumer-mesos/executor"
+	"github.com/urfave/cli"
+)
+
+func ExecutorAction(c *cli.Context) error {
+	driver, err := mesosexec.NewMesosExecutorDriver(mesosexec.DriverConfig{
+		Executor: executor.NewExecutor(executor.NewGonsumerConsumer),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = driver.Run()
+	return err
+}
@@ -3,7 +3,9 @@ package cmd
 import (
 	"fmt"
 	"github.com/serejja/gonsumer-mesos/framework"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func FmtGroups(groups []*framework.Group, indent int) string {
@@ -19,9 +21,29 @@ func FmtGroups(groups []*framework.Group, indent int) string {
 
 func FmtGroup(group *framework.Group, indent int) string {
 	s := Indent(indent) + fmt.Sprintf("ID: %s\n", group.ID)
+	s += Indent(indent) + fmt.Sprintf("stopped: %t\n", group.Stopped)
+	s += Indent(indent) + fmt.Sprintf("instances: %d\n", group.Instances)
 	s += Indent(indent) + fmt.Sprintf("subscription: %s\n", strings.Join(group.Subscriptions, ","))
 	s += Indent(indent) + fmt.Sprintf("bootstrap brokers: %s\n", strings.Join(group.BootstrapBrokers, ","))
+	s += Indent(indent) + fmt.Sprintf("cpus: %.2f, mem: %.2f, disk: %.2f\n", group.Cpus, group.Mem, group.Disk)
+	if group.Ports != 0 {
+		s += Indent(indent) + fmt.Sprintf("ports: %d\n", group.Ports)
+	}
+	if len(group.Options) != 0 {
+		s += Indent(indent) + fmt.Sprintf("options: %s\n", framework.FormatOptions(group.Options))
+	}
+	if len(group.Constraints) != 0 {
+		s += Indent(indent) + fmt.Sprintf("constraints: %s\n", framework.FormatConstraints(group.Constraints))
+	}
+	s += Indent(indent) + fmt.Sprintf("unreachable timeout: %s\n", group.UnreachableTimeout)
+	s += Indent(indent) + fmt.Sprintf("kill grace period: %s\n", group.KillGracePeriod)
 	s += Indent(indent) + FmtConsumers(group.Consumers, indent+1)
+	if len(group.Stopping) != 0 {
+		s += Indent(indent+1) + "removed consumers:\n"
+		for _, consumer := range group.Stopping {
+			s += FmtConsumer(consumer, indent+2)
+		}
+	}
 
 	return s
 }
@@ -38,7 +60,82 @@ func FmtConsumers(consumers []*framework.Consumer, indent int) string {
 }
 
 func FmtConsumer(consumer *framework.Consumer, indent int) string {
-	return ""
+	s := Indent(indent) + fmt.Sprintf("ID: %s\n", consumer.ID)
+	s += Indent(indent+1) + fmt.Sprintf("state: %s\n", consumer.State)
+	if consumer.TaskID != "" {
+		s += Indent(indent+1) + fmt.Sprintf("task: %s\n", consumer.TaskID)
+		s += Indent(indent+1) + fmt.Sprintf("slave: %s\n", consumer.SlaveID)
+		s += Indent(indent+1) + fmt.Sprintf("hostname: %s\n", consumer.Hostname)
+		if len(consumer.Ports) != 0 {
+			s += Indent(indent+1) + fmt.Sprintf("ports: %s\n", FmtPorts(consumer.Ports))
+		}
+	}
+	if !consumer.KillTime.IsZero() {
+		s += Indent(indent+1) + fmt.Sprintf("killed at: %s\n", consumer.KillTime.Format(time.RFC3339))
+	}
+	if consumer.State == framework.ConsumerStateUnreachable {
+		s += Indent(indent+1) + fmt.Sprintf("unreachable since: %s\n", consumer.UnreachableTime.Format(time.RFC3339))
+	}
+	if consumer.Failures != 0 {
+		s += Indent(indent+1) + fmt.Sprintf("failures: %d\n", consumer.Failures)
+		if consumer.State == framework.ConsumerStateFailed || consumer.State == framework.ConsumerStateLost {
+			s += Indent(indent+1) + fmt.Sprintf("restart at: %s\n", consumer.RestartTime.Format(time.RFC3339))
+		}
+	}
+	if len(consumer.Events) != 0 {
+		event := consumer.Events[len(consumer.Events)-1]
+		s += Indent(indent+1) + fmt.Sprintf("last event: %s %s\n", event.Time.Format(time.RFC3339), event.Message)
+	}
+	if consumer.Reason != "" {
+		s += Indent(indent+1) + fmt.Sprintf("reason: %s\n", consumer.Reason)
+	}
+	if consumer.Message != "" {
+		s += Indent(indent+1) + fmt.Sprintf("message: %s\n", consumer.Message)
+	}
+
+	return s
+}
+
+func FmtPorts(ports []uint64) string {
+	stringPorts := make([]string, 0, len(ports))
+	for _, port := range ports {
+		stringPorts = append(stringPorts, strconv.FormatUint(port, 10))
+	}
+
+	return strings.Join(stringPorts, ",")
+}
+
+func FmtReconcileStatus(status *framework.ReconcileStatus, indent int) string {
+	s := Indent(indent) + "reconciliation:\n"
+	s += Indent(indent+1) + fmt.Sprintf("tries: %d/%d\n", status.Tries, status.MaxTries)
+	if status.LastRun.IsZero() {
+		s += Indent(indent+1) + "last run: never\n"
+	} else {
+		s += Indent(indent+1) + fmt.Sprintf("last run: %s\n", status.LastRun.Format(time.RFC3339))
+	}
+	s += Indent(indent+1) + fmt.Sprintf("pending tasks: %s\n", strings.Join(status.PendingTasks, ","))
+
+	return s
+}
+
+func FmtFrameworkStatus(status *framework.FrameworkStatus, indent int) string {
+	s := Indent(indent) + "framework:\n"
+	s += Indent(indent+1) + fmt.Sprintf("id: %s\n", status.FrameworkID)
+	s += Indent(indent+1) + fmt.Sprintf("registered: %t\n", status.Registered)
+	s += Indent(indent+1) + fmt.Sprintf("master: %s\n", status.Master)
+	if status.LeadingMaster == "" {
+		s += Indent(indent+1) + "leading master: none\n"
+	} else {
+		s += Indent(indent+1) + fmt.Sprintf("leading master: %s\n", status.LeadingMaster)
+	}
+	if status.Principal != "" {
+		s += Indent(indent+1) + fmt.Sprintf("principal: %s\n", status.Principal)
+	}
+	if status.Error != "" {
+		s += Indent(indent+1) + fmt.Sprintf("error: %s\n", status.Error)
+	}
+
+	return s
 }
 
 func Indent(indent int) string {
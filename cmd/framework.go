@@ -4,6 +4,7 @@ import (
 	"github.com/serejja/gonsumer-mesos/framework"
 	"github.com/urfave/cli"
 	"os"
+	"strings"
 )
 
 const (
@@ -15,12 +16,47 @@ const (
 	FrameworkUserFlag    = "user"
 	FrameworkBindIPFlag  = "bind-ip"
 
+	FrameworkSchedulerAPIFlag = "scheduler-api"
+
+	FrameworkPrincipalFlag  = "principal"
+	FrameworkSecretFileFlag = "secret-file"
+
+	FrameworkConsumerCpusFlag = "consumer-cpus"
+	FrameworkConsumerMemFlag  = "consumer-mem"
+	FrameworkConsumerDiskFlag = "consumer-disk"
+
+	FrameworkUnreachableTimeoutFlag = "unreachable-timeout"
+	FrameworkKillGracePeriodFlag    = "kill-grace-period"
+
+	FrameworkLeaderElectionFlag = "leader-election"
+	FrameworkLeaderZkFlag       = "leader-zk"
+
+	FrameworkRefuseSecondsFlag = "refuse-seconds"
+
+	FrameworkRestartBackoffFlag    = "restart-backoff"
+	FrameworkRestartBackoffMaxFlag = "restart-backoff-max"
+	FrameworkRestartResetAfterFlag = "restart-reset-after"
+
+	FrameworkExecutorFlag  = "executor"
+	FrameworkArtifactsFlag = "artifacts"
+
 	ApiFlag = "api"
 	ApiEnv  = "GM_API"
 
 	GroupIDFlag               = "id"
 	GroupSubscriptionFlag     = "subscription"
 	GroupBootstrapBrokersFlag = "bootstrap-brokers"
+	GroupCpusFlag             = "cpus"
+	GroupMemFlag              = "mem"
+	GroupDiskFlag             = "disk"
+	GroupForceFlag            = "force"
+	GroupOptionsFlag          = "options"
+	GroupInstancesFlag        = "instances"
+	GroupConstraintsFlag      = "constraints"
+	GroupPortsFlag            = "ports"
+
+	GroupUnreachableTimeoutFlag = "unreachable-timeout"
+	GroupKillGracePeriodFlag    = "kill-grace-period"
 )
 
 func FrameworkAction(c *cli.Context) error {
@@ -37,6 +73,24 @@ func FrameworkAction(c *cli.Context) error {
 	config.FrameworkStorage = c.String(FrameworkStorageFlag)
 	config.User = c.String(FrameworkUserFlag)
 	config.BindIP = c.String(FrameworkBindIPFlag)
+	config.SchedulerAPI = c.String(FrameworkSchedulerAPIFlag)
+	config.Principal = c.String(FrameworkPrincipalFlag)
+	config.SecretFile = c.String(FrameworkSecretFileFlag)
+	config.ConsumerCpus = c.Float64(FrameworkConsumerCpusFlag)
+	config.ConsumerMem = c.Float64(FrameworkConsumerMemFlag)
+	config.ConsumerDisk = c.Float64(FrameworkConsumerDiskFlag)
+	config.UnreachableTimeout = c.Duration(FrameworkUnreachableTimeoutFlag)
+	config.KillGracePeriod = c.Duration(FrameworkKillGracePeriodFlag)
+	config.LeaderElection = c.Bool(FrameworkLeaderElectionFlag)
+	config.LeaderZk = c.String(FrameworkLeaderZkFlag)
+	config.RefuseSeconds = c.Float64(FrameworkRefuseSecondsFlag)
+	config.RestartBackoff.Delay = c.Duration(FrameworkRestartBackoffFlag)
+	config.RestartBackoff.MaxDelay = c.Duration(FrameworkRestartBackoffMaxFlag)
+	config.RestartBackoff.ResetAfter = c.Duration(FrameworkRestartResetAfterFlag)
+	config.ExecutorBinary = c.String(FrameworkExecutorFlag)
+	if artifacts := c.String(FrameworkArtifactsFlag); artifacts != "" {
+		config.Artifacts = strings.Split(artifacts, ",")
+	}
 
 	gonsumerFramework, err := framework.New(config)
 	if err != nil {
@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"github.com/serejja/gonsumer-mesos/api"
+	"github.com/serejja/gonsumer-mesos/framework"
 	"github.com/urfave/cli"
+	"strings"
 )
 
 func GroupAddAction(c *cli.Context) error {
@@ -15,10 +17,50 @@ func GroupAddAction(c *cli.Context) error {
 		return ErrGroupIDRequired
 	}
 
-	groupID := c.String(GroupIDFlag)
-	subscription := c.String(GroupSubscriptionFlag)
-	bootstrapBrokers := c.String(GroupBootstrapBrokersFlag)
+	options, err := framework.ParseOptions(c.String(GroupOptionsFlag))
+	if err != nil {
+		return err
+	}
+
+	constraints, err := framework.ParseConstraints(c.String(GroupConstraintsFlag))
+	if err != nil {
+		return err
+	}
+
+	group := &framework.Group{
+		ID:               c.String(GroupIDFlag),
+		Subscriptions:    strings.Split(c.String(GroupSubscriptionFlag), ","),
+		BootstrapBrokers: strings.Split(c.String(GroupBootstrapBrokersFlag), ","),
+		Cpus:             c.Float64(GroupCpusFlag),
+		Mem:              c.Float64(GroupMemFlag),
+		Disk:             c.Float64(GroupDiskFlag),
+		Options:          options,
+		Instances:        c.Int(GroupInstancesFlag),
+		Constraints:      constraints,
+		Ports:            c.Int(GroupPortsFlag),
+	}
+
+	if c.IsSet(GroupUnreachableTimeoutFlag) {
+		group.UnreachableTimeout = c.Duration(GroupUnreachableTimeoutFlag)
+	}
+
+	if c.IsSet(GroupKillGracePeriodFlag) {
+		group.KillGracePeriod = c.Duration(GroupKillGracePeriodFlag)
+	}
+
+	// zero values are sent only if given explicitly, otherwise the framework defaults apply
+	explicit := make([]string, 0)
+	for flag, param := range map[string]string{
+		GroupCpusFlag:      framework.ParamCpus,
+		GroupMemFlag:       framework.ParamMem,
+		GroupDiskFlag:      framework.ParamDisk,
+		GroupInstancesFlag: framework.ParamInstances,
+	} {
+		if c.IsSet(flag) {
+			explicit = append(explicit, param)
+		}
+	}
 
 	client := api.NewClient(apiURL)
-	return client.AddGroup(groupID, subscription, bootstrapBrokers)
+	return client.AddGroup(group, explicit...)
 }
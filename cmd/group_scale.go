@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/serejja/gonsumer-mesos/api"
+	"github.com/urfave/cli"
+)
+
+func GroupScaleAction(c *cli.Context) error {
+	apiURL := ResolveApi(c)
+	if apiURL == "" {
+		return ErrApiRequired
+	}
+
+	if !c.IsSet(GroupIDFlag) {
+		return ErrGroupIDRequired
+	}
+
+	if !c.IsSet(GroupInstancesFlag) {
+		return ErrInstancesRequired
+	}
+
+	client := api.NewClient(apiURL)
+	return client.ScaleGroup(c.String(GroupIDFlag), c.Int(GroupInstancesFlag))
+}
@@ -1,7 +1,20 @@
 package cmd
 
-import "github.com/urfave/cli"
+import (
+	"github.com/serejja/gonsumer-mesos/api"
+	"github.com/urfave/cli"
+)
 
 func GroupStopAction(c *cli.Context) error {
-	return nil
+	apiURL := ResolveApi(c)
+	if apiURL == "" {
+		return ErrApiRequired
+	}
+
+	if !c.IsSet(GroupIDFlag) {
+		return ErrGroupIDRequired
+	}
+
+	client := api.NewClient(apiURL)
+	return client.StopGroup(c.String(GroupIDFlag))
 }
@@ -1,7 +1,64 @@
 package cmd
 
-import "github.com/urfave/cli"
+import (
+	"github.com/serejja/gonsumer-mesos/api"
+	"github.com/serejja/gonsumer-mesos/framework"
+	"github.com/urfave/cli"
+	"strings"
+)
 
 func GroupUpdateAction(c *cli.Context) error {
-	return nil
+	apiURL := ResolveApi(c)
+	if apiURL == "" {
+		return ErrApiRequired
+	}
+
+	if !c.IsSet(GroupIDFlag) {
+		return ErrGroupIDRequired
+	}
+
+	update := new(framework.GroupUpdate)
+	if c.IsSet(GroupSubscriptionFlag) {
+		update.Subscriptions = strings.Split(c.String(GroupSubscriptionFlag), ",")
+	}
+
+	if c.IsSet(GroupBootstrapBrokersFlag) {
+		update.BootstrapBrokers = strings.Split(c.String(GroupBootstrapBrokersFlag), ",")
+	}
+
+	if c.IsSet(GroupCpusFlag) {
+		cpus := c.Float64(GroupCpusFlag)
+		update.Cpus = &cpus
+	}
+
+	if c.IsSet(GroupMemFlag) {
+		mem := c.Float64(GroupMemFlag)
+		update.Mem = &mem
+	}
+
+	if c.IsSet(GroupDiskFlag) {
+		disk := c.Float64(GroupDiskFlag)
+		update.Disk = &disk
+	}
+
+	if c.IsSet(GroupOptionsFlag) {
+		options, err := framework.ParseOptions(c.String(GroupOptionsFlag))
+		if err != nil {
+			return err
+		}
+		update.Options = options
+	}
+
+	if c.IsSet(GroupUnreachableTimeoutFlag) {
+		unreachableTimeout := c.Duration(GroupUnreachableTimeoutFlag)
+		update.UnreachableTimeout = &unreachableTimeout
+	}
+
+	if c.IsSet(GroupKillGracePeriodFlag) {
+		killGracePeriod := c.Duration(GroupKillGracePeriodFlag)
+		update.KillGracePeriod = &killGracePeriod
+	}
+
+	client := api.NewClient(apiURL)
+	return client.UpdateGroup(c.String(GroupIDFlag), update)
 }
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/serejja/gonsumer-mesos/api"
+	"github.com/urfave/cli"
+)
+
+func ReconcileStartAction(c *cli.Context) error {
+	apiURL := ResolveApi(c)
+	if apiURL == "" {
+		return ErrApiRequired
+	}
+
+	client := api.NewClient(apiURL)
+	status, err := client.Reconcile()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(FmtReconcileStatus(status, 0))
+	return nil
+}
+
+func ReconcileStatusAction(c *cli.Context) error {
+	apiURL := ResolveApi(c)
+	if apiURL == "" {
+		return ErrApiRequired
+	}
+
+	client := api.NewClient(apiURL)
+	status, err := client.ReconcileStatus()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(FmtReconcileStatus(status, 0))
+	return nil
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/serejja/gonsumer-mesos/api"
+	"github.com/urfave/cli"
+)
+
+func StatusAction(c *cli.Context) error {
+	apiURL := ResolveApi(c)
+	if apiURL == "" {
+		return ErrApiRequired
+	}
+
+	client := api.NewClient(apiURL)
+	status, err := client.Status()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(FmtFrameworkStatus(status, 0))
+	return nil
+}
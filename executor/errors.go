@@ -0,0 +1,7 @@
+package executor
+
+import "errors"
+
+var ErrNoBootstrapBrokers = errors.New("Group has no bootstrap brokers")
+
+var ErrNoSubscriptions = errors.New("Group has no subscriptions")
@@ -0,0 +1,273 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	mesosexec "github.com/mesos/mesos-go/executor"
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	util "github.com/mesos/mesos-go/mesosutil"
+	"github.com/serejja/gonsumer-mesos/framework"
+	"github.com/yanzay/log"
+	"sync"
+	"time"
+)
+
+// Consumer is a Kafka consumer run by the executor.
+type Consumer interface {
+	// Run starts consuming and blocks until the consumer is stopped or fails.
+	Run() error
+	// Stop commits offsets of processed messages and leaves the consumer group. Run returns once it is done.
+	Stop() error
+}
+
+// ConsumerFactory creates a Consumer for a given consumer configuration.
+type ConsumerFactory func(config *framework.ConsumerConfig) (Consumer, error)
+
+type GonsumerExecutor struct {
+	consumerFactory ConsumerFactory
+
+	lock     sync.Mutex
+	taskID   *mesos.TaskID
+	consumer Consumer
+	killed   bool
+	done     chan struct{}
+	// gracePeriod is how long the consumer may take to stop once the task is killed.
+	gracePeriod time.Duration
+	// reported is the ID of the last task reported killed, so a task is not reported twice.
+	reported string
+}
+
+func NewExecutor(consumerFactory ConsumerFactory) *GonsumerExecutor {
+	return &GonsumerExecutor{
+		consumerFactory: consumerFactory,
+	}
+}
+
+func (e *GonsumerExecutor) Registered(driver mesosexec.ExecutorDriver, executor *mesos.ExecutorInfo, frameworkInfo *mesos.FrameworkInfo, slave *mesos.SlaveInfo) {
+	log.Infof("[Registered] framework: %s slave: %s", frameworkInfo.GetId().GetValue(), slave.GetHostname())
+}
+
+func (e *GonsumerExecutor) Reregistered(driver mesosexec.ExecutorDriver, slave *mesos.SlaveInfo) {
+	log.Infof("[Reregistered] slave: %s", slave.GetHostname())
+}
+
+func (e *GonsumerExecutor) Disconnected(mesosexec.ExecutorDriver) {
+	log.Info("[Disconnected]")
+}
+
+func (e *GonsumerExecutor) LaunchTask(driver mesosexec.ExecutorDriver, task *mesos.TaskInfo) {
+	log.Infof("[LaunchTask] %s", task.GetTaskId().GetValue())
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.consumer != nil {
+		log.Errorf("Consumer for task %s is already running, refusing to launch task %s", e.taskID.GetValue(), task.GetTaskId().GetValue())
+		e.sendStatus(driver, task.GetTaskId(), mesos.TaskState_TASK_FAILED, "Executor is already running a task")
+		return
+	}
+
+	config := new(framework.ConsumerConfig)
+	err := json.Unmarshal(task.GetData(), config)
+	if err != nil {
+		log.Errorf("Failed to decode consumer configuration: %s", err)
+		e.sendStatus(driver, task.GetTaskId(), mesos.TaskState_TASK_FAILED, err.Error())
+		return
+	}
+
+	consumer, err := e.consumerFactory(config)
+	if err != nil {
+		log.Errorf("Failed to create consumer for group %s: %s", config.GroupID, err)
+		e.sendStatus(driver, task.GetTaskId(), mesos.TaskState_TASK_FAILED, err.Error())
+		return
+	}
+
+	e.taskID = task.GetTaskId()
+	e.consumer = consumer
+	e.killed = false
+	e.done = make(chan struct{})
+	e.gracePeriod = killGracePeriod(task)
+	e.sendStatus(driver, e.taskID, mesos.TaskState_TASK_RUNNING, "")
+
+	go e.run(driver, e.taskID, consumer, e.done)
+}
+
+func (e *GonsumerExecutor) KillTask(driver mesosexec.ExecutorDriver, taskID *mesos.TaskID) {
+	log.Infof("[KillTask] %s", taskID.GetValue())
+
+	e.lock.Lock()
+	if e.consumer == nil || e.taskID.GetValue() != taskID.GetValue() {
+		e.lock.Unlock()
+		log.Warningf("Received kill request for unknown task %s", taskID.GetValue())
+		return
+	}
+
+	if e.killed {
+		// the scheduler kills the task again once the grace period has expired
+		e.lock.Unlock()
+		log.Warningf("Consumer for task %s is still stopping, giving up", taskID.GetValue())
+		e.forceKill(driver, taskID, "Killed forcefully")
+		return
+	}
+
+	consumer, done := e.stop()
+	gracePeriod := e.gracePeriod
+	e.lock.Unlock()
+
+	e.sendStatus(driver, taskID, mesos.TaskState_TASK_KILLING, "Committing offsets")
+	go func() {
+		if !e.awaitStop(driver, taskID, consumer, done, gracePeriod) {
+			e.stopDriver(driver)
+		}
+	}()
+}
+
+func (e *GonsumerExecutor) FrameworkMessage(driver mesosexec.ExecutorDriver, message string) {
+	log.Infof("[FrameworkMessage] %s", message)
+}
+
+func (e *GonsumerExecutor) Shutdown(driver mesosexec.ExecutorDriver) {
+	log.Info("[Shutdown]")
+
+	e.lock.Lock()
+	taskID := e.taskID
+	stopping := e.consumer != nil && e.killed
+	consumer, done := e.stop()
+	gracePeriod := e.gracePeriod
+	e.lock.Unlock()
+
+	if consumer != nil {
+		e.sendStatus(driver, taskID, mesos.TaskState_TASK_KILLING, "Committing offsets")
+		e.awaitStop(driver, taskID, consumer, done, gracePeriod)
+	} else if stopping {
+		e.reportKilled(driver, taskID, "Killed forcefully")
+	}
+
+	e.stopDriver(driver)
+}
+
+func (e *GonsumerExecutor) Error(driver mesosexec.ExecutorDriver, message string) {
+	log.Errorf("[Error] %s", message)
+}
+
+// run blocks on a given consumer and reports how it has terminated unless it was killed.
+func (e *GonsumerExecutor) run(driver mesosexec.ExecutorDriver, taskID *mesos.TaskID, consumer Consumer, done chan struct{}) {
+	err := consumer.Run()
+
+	e.lock.Lock()
+	killed := e.killed
+	e.consumer = nil
+	e.lock.Unlock()
+	close(done)
+
+	if killed {
+		return
+	}
+
+	if err != nil {
+		log.Errorf("Consumer for task %s failed: %s", taskID.GetValue(), err)
+		e.sendStatus(driver, taskID, mesos.TaskState_TASK_FAILED, err.Error())
+		return
+	}
+
+	log.Infof("Consumer for task %s finished", taskID.GetValue())
+	e.sendStatus(driver, taskID, mesos.TaskState_TASK_FINISHED, "")
+}
+
+// stop marks the current consumer killed and returns it along with a channel closed once it has terminated.
+// Returns nils if there is no consumer or it is being stopped already. Must be called with lock held.
+func (e *GonsumerExecutor) stop() (Consumer, chan struct{}) {
+	if e.consumer == nil || e.killed {
+		return nil, nil
+	}
+
+	e.killed = true
+	return e.consumer, e.done
+}
+
+// awaitStop stops a given consumer and reports the task killed once the consumer has committed offsets and
+// left the consumer group. If that takes longer than the grace period, the task is reported killed anyway.
+// Returns false in that case.
+func (e *GonsumerExecutor) awaitStop(driver mesosexec.ExecutorDriver, taskID *mesos.TaskID, consumer Consumer, done chan struct{}, gracePeriod time.Duration) bool {
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- consumer.Stop()
+	}()
+
+	timeout := time.After(gracePeriod)
+	var err error
+	select {
+	case err = <-stopped:
+	case <-timeout:
+		e.forceKill(driver, taskID, fmt.Sprintf("Consumer did not stop within %s", gracePeriod))
+		return false
+	}
+
+	select {
+	case <-done:
+	case <-timeout:
+		e.forceKill(driver, taskID, fmt.Sprintf("Consumer did not stop within %s", gracePeriod))
+		return false
+	}
+
+	message := ""
+	if err != nil {
+		log.Errorf("Consumer for task %s failed to stop cleanly: %s", taskID.GetValue(), err)
+		message = err.Error()
+	}
+
+	e.reportKilled(driver, taskID, message)
+	return true
+}
+
+// forceKill reports a task killed without waiting for its consumer and stops the executor, so the consumer
+// does not outlive the task.
+func (e *GonsumerExecutor) forceKill(driver mesosexec.ExecutorDriver, taskID *mesos.TaskID, message string) {
+	log.Warningf("Killing task %s forcefully: %s", taskID.GetValue(), message)
+	if e.reportKilled(driver, taskID, message) {
+		e.stopDriver(driver)
+	}
+}
+
+// reportKilled sends TASK_KILLED for a given task unless it has been sent already. Returns false in that case.
+func (e *GonsumerExecutor) reportKilled(driver mesosexec.ExecutorDriver, taskID *mesos.TaskID, message string) bool {
+	e.lock.Lock()
+	if e.reported == taskID.GetValue() {
+		e.lock.Unlock()
+		return false
+	}
+	e.reported = taskID.GetValue()
+	e.lock.Unlock()
+
+	e.sendStatus(driver, taskID, mesos.TaskState_TASK_KILLED, message)
+	return true
+}
+
+func (e *GonsumerExecutor) stopDriver(driver mesosexec.ExecutorDriver) {
+	_, err := driver.Stop()
+	if err != nil {
+		log.Errorf("Failed to stop executor driver: %s", err)
+	}
+}
+
+// killGracePeriod returns how long a given task may take to stop once killed according to its kill policy.
+func killGracePeriod(task *mesos.TaskInfo) time.Duration {
+	gracePeriod := task.GetKillPolicy().GetGracePeriod()
+	if gracePeriod == nil {
+		return framework.DefaultKillGracePeriod
+	}
+
+	return time.Duration(gracePeriod.GetNanoseconds())
+}
+
+func (e *GonsumerExecutor) sendStatus(driver mesosexec.ExecutorDriver, taskID *mesos.TaskID, state mesos.TaskState, message string) {
+	status := util.NewTaskStatus(taskID, state)
+	if message != "" {
+		status.Message = proto.String(message)
+	}
+
+	_, err := driver.SendStatusUpdate(status)
+	if err != nil {
+		log.Errorf("Failed to send status update %s for task %s: %s", state, taskID.GetValue(), err)
+	}
+}
@@ -0,0 +1,249 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	util "github.com/mesos/mesos-go/mesosutil"
+	"github.com/serejja/gonsumer-mesos/framework"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type mockConsumer struct {
+	config *framework.ConsumerConfig
+	stop   chan error
+	// stopErr is returned by Stop, e.g. a failed offset commit
+	stopErr error
+	// blockStop makes Stop hang until closed
+	blockStop chan struct{}
+}
+
+func newMockConsumer(config *framework.ConsumerConfig) *mockConsumer {
+	return &mockConsumer{
+		config: config,
+		stop:   make(chan error, 1),
+	}
+}
+
+func (c *mockConsumer) Run() error {
+	return <-c.stop
+}
+
+func (c *mockConsumer) Stop() error {
+	if c.blockStop != nil {
+		<-c.blockStop
+	}
+
+	c.stop <- nil
+	return c.stopErr
+}
+
+// newTestExecutor returns an executor along with a channel receiving every consumer it creates.
+func newTestExecutor() (*GonsumerExecutor, chan *mockConsumer) {
+	consumers := make(chan *mockConsumer, 10)
+	executor := NewExecutor(func(config *framework.ConsumerConfig) (Consumer, error) {
+		consumer := newMockConsumer(config)
+		consumers <- consumer
+		return consumer, nil
+	})
+
+	return executor, consumers
+}
+
+func newTestTask(t *testing.T, id string) *mesos.TaskInfo {
+	data, err := json.Marshal(&framework.ConsumerConfig{
+		GroupID:          "foo",
+		Subscriptions:    []string{"bar"},
+		BootstrapBrokers: []string{"localhost:9092"},
+	})
+	require.Nil(t, err)
+
+	task := util.NewTaskInfo("task", util.NewTaskID(id), util.NewSlaveID("slave"), nil)
+	task.Data = data
+	return task
+}
+
+func awaitStatus(t *testing.T, driver *MockExecutorDriver) *mesos.TaskStatus {
+	select {
+	case status := <-driver.StatusUpdates:
+		return status
+	case <-time.After(time.Second):
+		require.FailNow(t, "Timed out waiting for status update")
+		return nil
+	}
+}
+
+func TestLaunchTaskFinished(t *testing.T) {
+	executor, consumers := newTestExecutor()
+	driver := NewMockExecutorDriver()
+
+	executor.LaunchTask(driver, newTestTask(t, "task-1"))
+	status := awaitStatus(t, driver)
+	assert.Equal(t, "task-1", status.GetTaskId().GetValue())
+	assert.Equal(t, mesos.TaskState_TASK_RUNNING, status.GetState())
+
+	consumer := <-consumers
+	assert.Equal(t, "foo", consumer.config.GroupID)
+	assert.Equal(t, []string{"bar"}, consumer.config.Subscriptions)
+	assert.Equal(t, []string{"localhost:9092"}, consumer.config.BootstrapBrokers)
+
+	consumer.stop <- nil
+	status = awaitStatus(t, driver)
+	assert.Equal(t, "task-1", status.GetTaskId().GetValue())
+	assert.Equal(t, mesos.TaskState_TASK_FINISHED, status.GetState())
+}
+
+func TestLaunchTaskConsumerFailed(t *testing.T) {
+	executor, consumers := newTestExecutor()
+	driver := NewMockExecutorDriver()
+
+	executor.LaunchTask(driver, newTestTask(t, "task-1"))
+	assert.Equal(t, mesos.TaskState_TASK_RUNNING, awaitStatus(t, driver).GetState())
+
+	consumer := <-consumers
+	consumer.stop <- errors.New("boom!")
+	status := awaitStatus(t, driver)
+	assert.Equal(t, mesos.TaskState_TASK_FAILED, status.GetState())
+	assert.Equal(t, "boom!", status.GetMessage())
+}
+
+func TestLaunchTaskInvalidData(t *testing.T) {
+	executor, consumers := newTestExecutor()
+	driver := NewMockExecutorDriver()
+
+	task := newTestTask(t, "task-1")
+	task.Data = []byte("not a config")
+	executor.LaunchTask(driver, task)
+
+	status := awaitStatus(t, driver)
+	assert.Equal(t, mesos.TaskState_TASK_FAILED, status.GetState())
+	assert.NotEmpty(t, status.GetMessage())
+	assert.Empty(t, consumers)
+}
+
+func TestLaunchTaskConsumerError(t *testing.T) {
+	executor := NewExecutor(func(config *framework.ConsumerConfig) (Consumer, error) {
+		return nil, ErrNoSubscriptions
+	})
+	driver := NewMockExecutorDriver()
+
+	executor.LaunchTask(driver, newTestTask(t, "task-1"))
+	status := awaitStatus(t, driver)
+	assert.Equal(t, mesos.TaskState_TASK_FAILED, status.GetState())
+	assert.Equal(t, ErrNoSubscriptions.Error(), status.GetMessage())
+}
+
+func TestKillTask(t *testing.T) {
+	executor, _ := newTestExecutor()
+	driver := NewMockExecutorDriver()
+
+	executor.LaunchTask(driver, newTestTask(t, "task-1"))
+	assert.Equal(t, mesos.TaskState_TASK_RUNNING, awaitStatus(t, driver).GetState())
+
+	// unknown tasks should be ignored
+	executor.KillTask(driver, util.NewTaskID("task-2"))
+	assert.Empty(t, driver.StatusUpdates)
+
+	executor.KillTask(driver, util.NewTaskID("task-1"))
+	status := awaitStatus(t, driver)
+	assert.Equal(t, "task-1", status.GetTaskId().GetValue())
+	assert.Equal(t, mesos.TaskState_TASK_KILLING, status.GetState())
+	status = awaitStatus(t, driver)
+	assert.Equal(t, "task-1", status.GetTaskId().GetValue())
+	assert.Equal(t, mesos.TaskState_TASK_KILLED, status.GetState())
+	assert.Empty(t, status.GetMessage())
+	assert.Empty(t, driver.StatusUpdates)
+	assert.Empty(t, driver.Stops)
+
+	// executor should be able to run a new task once the previous one is killed
+	executor.LaunchTask(driver, newTestTask(t, "task-3"))
+	assert.Equal(t, mesos.TaskState_TASK_RUNNING, awaitStatus(t, driver).GetState())
+}
+
+func TestKillTaskCommitError(t *testing.T) {
+	executor, consumers := newTestExecutor()
+	driver := NewMockExecutorDriver()
+
+	executor.LaunchTask(driver, newTestTask(t, "task-1"))
+	assert.Equal(t, mesos.TaskState_TASK_RUNNING, awaitStatus(t, driver).GetState())
+	consumer := <-consumers
+	consumer.stopErr = errors.New("commit failed")
+
+	executor.KillTask(driver, util.NewTaskID("task-1"))
+	assert.Equal(t, mesos.TaskState_TASK_KILLING, awaitStatus(t, driver).GetState())
+	status := awaitStatus(t, driver)
+	assert.Equal(t, mesos.TaskState_TASK_KILLED, status.GetState())
+	assert.Equal(t, "commit failed", status.GetMessage())
+}
+
+func TestKillTaskGracePeriodExpired(t *testing.T) {
+	executor, consumers := newTestExecutor()
+	driver := NewMockExecutorDriver()
+
+	task := newTestTask(t, "task-1")
+	task.KillPolicy = &mesos.KillPolicy{GracePeriod: &mesos.DurationInfo{Nanoseconds: proto.Int64(int64(10 * time.Millisecond))}}
+	executor.LaunchTask(driver, task)
+	assert.Equal(t, mesos.TaskState_TASK_RUNNING, awaitStatus(t, driver).GetState())
+	consumer := <-consumers
+	consumer.blockStop = make(chan struct{})
+	defer close(consumer.blockStop)
+
+	executor.KillTask(driver, util.NewTaskID("task-1"))
+	assert.Equal(t, mesos.TaskState_TASK_KILLING, awaitStatus(t, driver).GetState())
+	status := awaitStatus(t, driver)
+	assert.Equal(t, mesos.TaskState_TASK_KILLED, status.GetState())
+	assert.Contains(t, status.GetMessage(), "did not stop within 10ms")
+
+	// executor exits so the hanging consumer does not outlive its task
+	select {
+	case <-driver.Stops:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for executor driver to stop")
+	}
+}
+
+func TestKillTaskTwice(t *testing.T) {
+	executor, consumers := newTestExecutor()
+	driver := NewMockExecutorDriver()
+
+	executor.LaunchTask(driver, newTestTask(t, "task-1"))
+	assert.Equal(t, mesos.TaskState_TASK_RUNNING, awaitStatus(t, driver).GetState())
+	consumer := <-consumers
+	consumer.blockStop = make(chan struct{})
+	defer close(consumer.blockStop)
+
+	executor.KillTask(driver, util.NewTaskID("task-1"))
+	assert.Equal(t, mesos.TaskState_TASK_KILLING, awaitStatus(t, driver).GetState())
+
+	// the scheduler kills the task again once the grace period expires on its side
+	executor.KillTask(driver, util.NewTaskID("task-1"))
+	status := awaitStatus(t, driver)
+	assert.Equal(t, mesos.TaskState_TASK_KILLED, status.GetState())
+	assert.Equal(t, "Killed forcefully", status.GetMessage())
+	assert.Equal(t, 1, driver.StopCount)
+}
+
+func TestKillGracePeriod(t *testing.T) {
+	task := newTestTask(t, "task-1")
+	assert.Equal(t, framework.DefaultKillGracePeriod, killGracePeriod(task))
+
+	task.KillPolicy = &mesos.KillPolicy{GracePeriod: &mesos.DurationInfo{Nanoseconds: proto.Int64(int64(time.Minute))}}
+	assert.Equal(t, time.Minute, killGracePeriod(task))
+}
+
+func TestShutdown(t *testing.T) {
+	executor, _ := newTestExecutor()
+	driver := NewMockExecutorDriver()
+
+	executor.LaunchTask(driver, newTestTask(t, "task-1"))
+	assert.Equal(t, mesos.TaskState_TASK_RUNNING, awaitStatus(t, driver).GetState())
+
+	executor.Shutdown(driver)
+	assert.Equal(t, mesos.TaskState_TASK_KILLING, awaitStatus(t, driver).GetState())
+	assert.Equal(t, mesos.TaskState_TASK_KILLED, awaitStatus(t, driver).GetState())
+	assert.Equal(t, 1, driver.StopCount)
+}
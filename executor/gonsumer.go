@@ -0,0 +1,182 @@
+package executor
+
+import (
+	"fmt"
+	"github.com/elodina/siesta"
+	"github.com/serejja/gonsumer"
+	"github.com/serejja/gonsumer-mesos/framework"
+	"github.com/yanzay/log"
+	"strconv"
+	"sync"
+)
+
+const (
+	OptionClientID  = "client.id"
+	OptionFetchSize = "fetch.size"
+)
+
+// GonsumerConsumer consumes all partitions of group subscriptions with a Gonsumer consumer.
+type GonsumerConsumer struct {
+	config    *framework.ConsumerConfig
+	connector siesta.Connector
+	consumer  gonsumer.Consumer
+
+	// uncommitted are offsets of processed messages that failed to commit, retried once the consumer stops.
+	uncommitted     map[topicPartition]int64
+	uncommittedLock sync.Mutex
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// NewGonsumerConsumer is a ConsumerFactory creating Gonsumer backed consumers.
+func NewGonsumerConsumer(config *framework.ConsumerConfig) (Consumer, error) {
+	if len(config.BootstrapBrokers) == 0 {
+		return nil, ErrNoBootstrapBrokers
+	}
+
+	if len(config.Subscriptions) == 0 {
+		return nil, ErrNoSubscriptions
+	}
+
+	connectorConfig, err := newConnectorConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	connector, err := siesta.NewDefaultConnector(connectorConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	consumer := &GonsumerConsumer{
+		config:      config,
+		connector:   connector,
+		uncommitted: make(map[topicPartition]int64),
+	}
+
+	consumerConfig := gonsumer.NewConfig()
+	consumerConfig.Group = config.GroupID
+	consumerConfig.Strategy = consumer.commitStrategy
+	consumer.consumer = gonsumer.New(connector, consumerConfig)
+
+	return consumer, nil
+}
+
+func (c *GonsumerConsumer) Run() error {
+	metadata, err := c.connector.GetTopicMetadata(c.config.Subscriptions)
+	if err != nil {
+		return err
+	}
+
+	for _, topic := range metadata.TopicsMetadata {
+		if topic.Error != nil {
+			c.consumer.Stop()
+			return fmt.Errorf("Failed to fetch metadata for topic %s: %s", topic.Topic, topic.Error)
+		}
+
+		for _, partition := range topic.PartitionsMetadata {
+			log.Infof("Adding %s/%d to consumer", topic.Topic, partition.PartitionID)
+			err = c.consumer.Add(topic.Topic, partition.PartitionID)
+			if err != nil {
+				c.consumer.Stop()
+				return err
+			}
+		}
+	}
+
+	c.consumer.Join()
+	return nil
+}
+
+// Stop stops fetching all assigned partitions, so no message is processed without its offset being committed,
+// commits offsets that failed to commit and closes broker connections.
+func (c *GonsumerConsumer) Stop() error {
+	for topic, partitions := range c.consumer.Assignment() {
+		for _, partition := range partitions {
+			log.Infof("Removing %s/%d from consumer", topic, partition)
+			err := c.consumer.Remove(topic, partition)
+			if err != nil {
+				log.Warningf("Failed to remove %s/%d from consumer: %s", topic, partition, err)
+			}
+		}
+	}
+
+	err := c.commitUncommitted()
+	c.consumer.Stop()
+	<-c.connector.Close()
+	return err
+}
+
+// commitUncommitted retries commits that have failed while consuming.
+func (c *GonsumerConsumer) commitUncommitted() error {
+	c.uncommittedLock.Lock()
+	defer c.uncommittedLock.Unlock()
+
+	var lastErr error
+	for tp, offset := range c.uncommitted {
+		err := c.consumer.Commit(tp.topic, tp.partition, offset)
+		if err != nil {
+			lastErr = fmt.Errorf("Failed to commit offset %d for %s/%d: %s", offset, tp.topic, tp.partition, err)
+			continue
+		}
+
+		delete(c.uncommitted, tp)
+	}
+
+	return lastErr
+}
+
+func newConnectorConfig(consumerConfig *framework.ConsumerConfig) (*siesta.ConnectorConfig, error) {
+	config := siesta.NewConnectorConfig()
+	config.BrokerList = consumerConfig.BootstrapBrokers
+
+	for key, value := range consumerConfig.Options {
+		switch key {
+		case OptionClientID:
+			config.ClientID = value
+		case OptionFetchSize:
+			fetchSize, err := strconv.ParseInt(value, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid %s option: %s", key, err)
+			}
+			config.FetchSize = int32(fetchSize)
+		default:
+			log.Warningf("Ignoring unknown consumer option %s", key)
+		}
+	}
+
+	return config, nil
+}
+
+// commitStrategy logs fetched messages and commits the offset of the last one. Failed commits are retried on Stop.
+func (c *GonsumerConsumer) commitStrategy(data *gonsumer.FetchData, consumer *gonsumer.KafkaPartitionConsumer) {
+	if data.Error != nil {
+		log.Errorf("Fetch failed: %s", data.Error)
+		return
+	}
+
+	if len(data.Messages) == 0 {
+		return
+	}
+
+	for _, message := range data.Messages {
+		log.Debugf("%s/%d/%d: %s", message.Topic, message.Partition, message.Offset, message.Value)
+	}
+
+	last := data.Messages[len(data.Messages)-1]
+	tp := topicPartition{topic: last.Topic, partition: last.Partition}
+	err := consumer.Commit(last.Offset)
+
+	c.uncommittedLock.Lock()
+	defer c.uncommittedLock.Unlock()
+	if err != nil {
+		log.Errorf("Failed to commit offset: %s", err)
+		c.uncommitted[tp] = last.Offset
+		return
+	}
+
+	delete(c.uncommitted, tp)
+}
@@ -0,0 +1,76 @@
+package executor
+
+import mesos "github.com/mesos/mesos-go/mesosproto"
+
+type MockExecutorDriver struct {
+	StartStatus mesos.Status
+	StartError  error
+
+	StopStatus mesos.Status
+	StopError  error
+	StopCount  int
+	// Stops receives a value every time the driver is stopped, possibly from a separate goroutine.
+	Stops chan struct{}
+
+	AbortStatus mesos.Status
+	AbortError  error
+
+	JoinStatus mesos.Status
+	JoinError  error
+
+	RunStatus mesos.Status
+	RunError  error
+
+	SendStatusUpdateStatus mesos.Status
+	SendStatusUpdateError  error
+	// StatusUpdates receives every sent status update. Status updates may be sent from a separate goroutine.
+	StatusUpdates chan *mesos.TaskStatus
+
+	SendFrameworkMessageStatus mesos.Status
+	SendFrameworkMessageError  error
+}
+
+func NewMockExecutorDriver() *MockExecutorDriver {
+	return &MockExecutorDriver{
+		StartStatus:                mesos.Status_DRIVER_RUNNING,
+		StopStatus:                 mesos.Status_DRIVER_RUNNING,
+		Stops:                      make(chan struct{}, 10),
+		AbortStatus:                mesos.Status_DRIVER_RUNNING,
+		JoinStatus:                 mesos.Status_DRIVER_RUNNING,
+		RunStatus:                  mesos.Status_DRIVER_RUNNING,
+		SendStatusUpdateStatus:     mesos.Status_DRIVER_RUNNING,
+		StatusUpdates:              make(chan *mesos.TaskStatus, 100),
+		SendFrameworkMessageStatus: mesos.Status_DRIVER_RUNNING,
+	}
+}
+
+func (e *MockExecutorDriver) Start() (mesos.Status, error) {
+	return e.StartStatus, e.StartError
+}
+
+func (e *MockExecutorDriver) Stop() (mesos.Status, error) {
+	e.StopCount++
+	e.Stops <- struct{}{}
+	return e.StopStatus, e.StopError
+}
+
+func (e *MockExecutorDriver) Abort() (mesos.Status, error) {
+	return e.AbortStatus, e.AbortError
+}
+
+func (e *MockExecutorDriver) Join() (mesos.Status, error) {
+	return e.JoinStatus, e.JoinError
+}
+
+func (e *MockExecutorDriver) Run() (mesos.Status, error) {
+	return e.RunStatus, e.RunError
+}
+
+func (e *MockExecutorDriver) SendStatusUpdate(status *mesos.TaskStatus) (mesos.Status, error) {
+	e.StatusUpdates <- status
+	return e.SendStatusUpdateStatus, e.SendStatusUpdateError
+}
+
+func (e *MockExecutorDriver) SendFrameworkMessage(message string) (mesos.Status, error) {
+	return e.SendFrameworkMessageStatus, e.SendFrameworkMessageError
+}
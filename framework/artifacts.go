@@ -0,0 +1,60 @@
+package framework
+
+import (
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// artifactsPath is the HTTPServer route executors fetch their files from.
+	artifactsPath = "/resource/"
+
+	// executorBinary is the name the running binary is served under, so executorCommand can find it in the sandbox.
+	executorBinary = "gonsumer-mesos"
+)
+
+// artifacts maps names of files served to executors to their local paths.
+func artifacts(config GonsumerFrameworkConfig) map[string]string {
+	files := make(map[string]string)
+	for _, artifact := range config.Artifacts {
+		files[filepath.Base(artifact)] = artifact
+	}
+	files[executorBinary] = config.ExecutorBinary
+
+	return files
+}
+
+// artifactURIs returns URIs of all files served to executors. The executor binary always goes first.
+func artifactURIs(config GonsumerFrameworkConfig) []*mesos.CommandInfo_URI {
+	uris := []*mesos.CommandInfo_URI{
+		{
+			Value:      proto.String(artifactURL(config.Api, executorBinary)),
+			Executable: proto.Bool(true),
+			Extract:    proto.Bool(false),
+		},
+	}
+
+	for _, artifact := range config.Artifacts {
+		name := filepath.Base(artifact)
+		if name == executorBinary {
+			continue
+		}
+
+		uris = append(uris, &mesos.CommandInfo_URI{
+			Value: proto.String(artifactURL(config.Api, name)),
+		})
+	}
+
+	return uris
+}
+
+func artifactURL(api string, name string) string {
+	if !strings.HasPrefix(api, "http://") {
+		api = "http://" + api
+	}
+
+	return strings.TrimSuffix(api, "/") + artifactsPath + url.PathEscape(name)
+}
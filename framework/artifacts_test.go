@@ -0,0 +1,30 @@
+package framework
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestArtifactURIs(t *testing.T) {
+	config := NewConfig()
+	config.Api = "http://master:6666"
+	config.ExecutorBinary = "/usr/bin/gonsumer"
+	config.Artifacts = []string{"/opt/gonsumer/config.json", "/opt/gonsumer/lib.tar.gz"}
+
+	uris := artifactURIs(config)
+	require.Len(t, uris, 3)
+	assert.Equal(t, "http://master:6666/resource/gonsumer-mesos", uris[0].GetValue())
+	assert.True(t, uris[0].GetExecutable())
+	assert.Equal(t, "http://master:6666/resource/config.json", uris[1].GetValue())
+	assert.False(t, uris[1].GetExecutable())
+	assert.Equal(t, "http://master:6666/resource/lib.tar.gz", uris[2].GetValue())
+
+	// api without scheme should still produce valid urls
+	config.Api = "master:6666"
+	assert.Equal(t, "http://master:6666/resource/gonsumer-mesos", artifactURIs(config)[0].GetValue())
+
+	files := artifacts(config)
+	assert.Equal(t, "/usr/bin/gonsumer", files["gonsumer-mesos"])
+	assert.Equal(t, "/opt/gonsumer/lib.tar.gz", files["lib.tar.gz"])
+}
@@ -0,0 +1,53 @@
+package framework
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/mesos/mesos-go/auth"
+	"github.com/mesos/mesos-go/auth/sasl"
+	_ "github.com/mesos/mesos-go/auth/sasl/mech/crammd5"
+	"github.com/mesos/mesos-go/mesosproto"
+	"golang.org/x/net/context"
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+// newCredential returns a credential to authenticate the framework with or nil if no principal is configured.
+func newCredential(config GonsumerFrameworkConfig) (*mesosproto.Credential, error) {
+	if config.Principal == "" {
+		if config.SecretFile != "" {
+			return nil, ErrSecretWithoutPrincipal
+		}
+
+		return nil, nil
+	}
+
+	credential := &mesosproto.Credential{
+		Principal: proto.String(config.Principal),
+	}
+
+	if config.SecretFile != "" {
+		secret, err := ioutil.ReadFile(config.SecretFile)
+		if err != nil {
+			return nil, err
+		}
+
+		// secret files usually end with a newline which is not a part of the secret
+		credential.Secret = proto.String(strings.TrimSpace(string(secret)))
+	}
+
+	return credential, nil
+}
+
+// authContext makes the driver authenticate with SASL CRAM-MD5 from a given binding address.
+func authContext(bindingAddress net.IP) func(context.Context) context.Context {
+	return func(ctx context.Context) context.Context {
+		ctx = auth.WithLoginProvider(ctx, sasl.ProviderName)
+		return sasl.WithBindingAddress(ctx, bindingAddress)
+	}
+}
+
+// isAuthenticationError checks whether a driver error is caused by failed framework authentication.
+func isAuthenticationError(message string) bool {
+	return message == auth.AuthenticationFailed.Error() || strings.Contains(strings.ToLower(message), "authenticat")
+}
@@ -0,0 +1,37 @@
+package framework
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestNewCredential(t *testing.T) {
+	config := NewConfig()
+	credential, err := newCredential(config)
+	assert.Nil(t, err)
+	assert.Nil(t, credential)
+
+	secretFile, err := ioutil.TempFile("", "gonsumer-secret")
+	require.Nil(t, err)
+	defer os.Remove(secretFile.Name())
+	_, err = secretFile.WriteString("s3cr3t\n")
+	require.Nil(t, err)
+	require.Nil(t, secretFile.Close())
+
+	config.SecretFile = secretFile.Name()
+	_, err = newCredential(config)
+	assert.Equal(t, ErrSecretWithoutPrincipal, err)
+
+	config.Principal = "gonsumer"
+	credential, err = newCredential(config)
+	require.Nil(t, err)
+	assert.Equal(t, "gonsumer", credential.GetPrincipal())
+	assert.Equal(t, "s3cr3t", credential.GetSecret())
+
+	config.SecretFile = secretFile.Name() + ".missing"
+	_, err = newCredential(config)
+	assert.NotNil(t, err)
+}
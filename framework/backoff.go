@@ -0,0 +1,27 @@
+package framework
+
+import "time"
+
+// RestartBackoff describes how long failed consumers wait before being relaunched.
+type RestartBackoff struct {
+	// Delay before the first restart. Doubles with each consecutive failure.
+	Delay time.Duration
+	// MaxDelay caps the restart delay.
+	MaxDelay time.Duration
+	// ResetAfter is how long a task should stay running for the failure count of its consumer to be reset.
+	ResetAfter time.Duration
+}
+
+// delay returns how long to wait before restarting a consumer after a given number of consecutive failures.
+func (b RestartBackoff) delay(failures int) time.Duration {
+	delay := b.Delay
+	for i := 1; i < failures && delay < b.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+
+	return delay
+}
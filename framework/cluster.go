@@ -2,7 +2,10 @@ package framework
 
 import (
 	"encoding/json"
+	"sort"
+	"strconv"
 	"sync"
+	"time"
 )
 
 type Cluster interface {
@@ -10,14 +13,24 @@ type Cluster interface {
 	GetFrameworkID() string
 
 	AddGroup(group *Group)
+	// RemoveGroup removes a group. A group with consumers still stopping is kept among stopping groups until
+	// they stop, see RemoveStoppedGroups.
+	RemoveGroup(id string)
 	GetGroup(id string) *Group
 	ExistsGroup(id string) bool
 	GetGroups() []*Group
+	// GetStoppingGroups returns removed groups with consumers still stopping.
+	GetStoppingGroups() []*Group
+	// RemoveStoppedGroups forgets stopping groups none of consumers of which are stopping any longer.
+	RemoveStoppedGroups()
+
+	GetConsumerByTaskID(taskID string) (*Group, *Consumer)
 }
 
 type gonsumerClusterJSON struct {
 	FrameworkID string   `json:"framework_id"`
 	Groups      []*Group `json:"groups"`
+	Stopping    []*Group `json:"stopping,omitempty"`
 }
 
 type GonsumerCluster struct {
@@ -25,6 +38,8 @@ type GonsumerCluster struct {
 
 	frameworkID string
 	groups      map[string]*Group
+	// stopping are removed groups whose consumers have not stopped yet, so their kills can still be escalated.
+	stopping []*Group
 }
 
 func NewGonsumerCluster() *GonsumerCluster {
@@ -54,6 +69,21 @@ func (c *GonsumerCluster) AddGroup(group *Group) {
 	c.groups[group.ID] = group
 }
 
+func (c *GonsumerCluster) RemoveGroup(id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	group, exists := c.groups[id]
+	if !exists {
+		return
+	}
+
+	delete(c.groups, id)
+	if group.hasStoppingConsumers() {
+		c.stopping = append(c.stopping, group)
+	}
+}
+
 func (c *GonsumerCluster) GetGroup(id string) *Group {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -77,10 +107,58 @@ func (c *GonsumerCluster) GetGroups() []*Group {
 	for _, group := range c.groups {
 		groups = append(groups, group)
 	}
+	sort.Sort(byGroupID(groups))
 
 	return groups
 }
 
+func (c *GonsumerCluster) GetStoppingGroups() []*Group {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]*Group(nil), c.stopping...)
+}
+
+func (c *GonsumerCluster) RemoveStoppedGroups() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	stopping := c.stopping[:0]
+	for _, group := range c.stopping {
+		if group.hasStoppingConsumers() {
+			stopping = append(stopping, group)
+		}
+	}
+	c.stopping = stopping
+}
+
+// GetConsumerByTaskID returns a consumer that is assigned a given task ID along with its group, stopping groups
+// included. Returns nils if no such consumer exists.
+func (c *GonsumerCluster) GetConsumerByTaskID(taskID string) (*Group, *Consumer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if taskID == "" {
+		return nil, nil
+	}
+
+	for _, group := range c.groups {
+		consumer := group.GetConsumerByTaskID(taskID)
+		if consumer != nil {
+			return group, consumer
+		}
+	}
+
+	for _, group := range c.stopping {
+		consumer := group.GetConsumerByTaskID(taskID)
+		if consumer != nil {
+			return group, consumer
+		}
+	}
+
+	return nil, nil
+}
+
 func (c *GonsumerCluster) MarshalJSON() ([]byte, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -88,11 +166,14 @@ func (c *GonsumerCluster) MarshalJSON() ([]byte, error) {
 	cluster := gonsumerClusterJSON{
 		FrameworkID: c.frameworkID,
 		Groups:      make([]*Group, 0, len(c.groups)),
+		Stopping:    c.stopping,
 	}
 
 	for _, group := range c.groups {
 		cluster.Groups = append(cluster.Groups, group)
 	}
+	// sorted, so unchanged state marshals to the same contents which storages need not write again
+	sort.Sort(byGroupID(cluster.Groups))
 
 	return json.Marshal(cluster)
 }
@@ -112,6 +193,7 @@ func (c *GonsumerCluster) UnmarshalJSON(bytes []byte) error {
 	for _, group := range cluster.Groups {
 		c.groups[group.ID] = group
 	}
+	c.stopping = cluster.Stopping
 
 	return nil
 }
@@ -121,10 +203,148 @@ type Group struct {
 	Subscriptions    []string `json:"subscriptions"`
 	BootstrapBrokers []string `json:"bootstrap_brokers"`
 
+	Cpus float64 `json:"cpus"`
+	Mem  float64 `json:"mem"`
+	Disk float64 `json:"disk"`
+	// Ports is the number of ports allocated for each consumer.
+	Ports int `json:"ports,omitempty"`
+
+	// Instances is the desired number of consumers in this group.
+	Instances int `json:"instances"`
+
+	// Options are passed to each Gonsumer consumer of this group.
+	Options map[string]string `json:"options,omitempty"`
+
+	// Constraints restrict which offers consumers of this group may be placed onto.
+	Constraints []*Constraint `json:"constraints,omitempty"`
+
+	// UnreachableTimeout is how long to wait for an unreachable consumer to come back before launching a replacement.
+	UnreachableTimeout time.Duration `json:"unreachable_timeout"`
+	// KillGracePeriod is how long a killed consumer may take to commit offsets and leave the consumer group
+	// before it is killed forcefully.
+	KillGracePeriod time.Duration `json:"kill_grace_period"`
+
+	// Stopped groups keep their configuration but are not scheduled.
+	Stopped bool `json:"stopped"`
+
 	Consumers []*Consumer `json:"consumers"`
+	// Stopping are consumers removed by scaling down whose tasks have not terminated yet.
+	Stopping []*Consumer `json:"stopping,omitempty"`
 }
 
-type Consumer struct {
-	ID string `json:"id"`
-	//Assignments
+func NewGroup(id string) *Group {
+	return &Group{
+		ID:                 id,
+		Instances:          1,
+		UnreachableTimeout: DefaultUnreachableTimeout,
+		KillGracePeriod:    DefaultKillGracePeriod,
+		Consumers:          []*Consumer{NewConsumer("0")},
+	}
+}
+
+// Scale adds or removes consumers so that this group has a given number of them. Consumers that were launched
+// most recently are removed first. Returns removed consumers.
+func (g *Group) Scale(instances int) []*Consumer {
+	g.Instances = instances
+
+	for len(g.Consumers) < instances {
+		consumer := NewConsumer(g.nextConsumerID())
+		if g.Stopped {
+			consumer.State = ConsumerStateStopped
+		}
+		g.Consumers = append(g.Consumers, consumer)
+	}
+
+	if len(g.Consumers) <= instances {
+		return nil
+	}
+
+	consumers := make([]*Consumer, len(g.Consumers))
+	copy(consumers, g.Consumers)
+	sort.Stable(byLaunchTime(consumers))
+
+	g.Consumers = consumers[:instances]
+	sort.Sort(byConsumerID(g.Consumers))
+	return consumers[instances:]
+}
+
+func (g *Group) nextConsumerID() string {
+	next := 0
+	for _, consumer := range g.Consumers {
+		id, err := strconv.Atoi(consumer.ID)
+		if err == nil && id >= next {
+			next = id + 1
+		}
+	}
+
+	return strconv.Itoa(next)
+}
+
+// PendingConsumers returns consumers of this group that are waiting for an offer to be launched.
+func (g *Group) PendingConsumers() []*Consumer {
+	return g.ConsumersWithState(ConsumerStatePending)
+}
+
+func (g *Group) ConsumersWithState(state ConsumerState) []*Consumer {
+	consumers := make([]*Consumer, 0)
+	for _, consumer := range g.Consumers {
+		if consumer.State == state {
+			consumers = append(consumers, consumer)
+		}
+	}
+
+	return consumers
 }
+
+func (g *Group) GetConsumerByTaskID(taskID string) *Consumer {
+	for _, consumer := range g.Consumers {
+		if consumer.TaskID == taskID {
+			return consumer
+		}
+	}
+
+	for _, consumer := range g.Stopping {
+		if consumer.TaskID == taskID {
+			return consumer
+		}
+	}
+
+	return nil
+}
+
+// allConsumers returns consumers of this group along with removed consumers that are still stopping.
+func (g *Group) allConsumers() []*Consumer {
+	consumers := make([]*Consumer, 0, len(g.Consumers)+len(g.Stopping))
+	consumers = append(consumers, g.Consumers...)
+	return append(consumers, g.Stopping...)
+}
+
+// hasStoppingConsumers returns true if tasks of some consumers of this group are being killed.
+func (g *Group) hasStoppingConsumers() bool {
+	for _, consumer := range g.allConsumers() {
+		if consumer.State == ConsumerStateStopping {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeStopping forgets a given removed consumer once its task has terminated.
+// Returns false if the consumer is not one of removed consumers.
+func (g *Group) removeStopping(consumer *Consumer) bool {
+	for i, stopping := range g.Stopping {
+		if stopping == consumer {
+			g.Stopping = append(g.Stopping[:i], g.Stopping[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+type byGroupID []*Group
+
+func (g byGroupID) Len() int           { return len(g) }
+func (g byGroupID) Swap(i, j int)      { g[i], g[j] = g[j], g[i] }
+func (g byGroupID) Less(i, j int) bool { return g[i].ID < g[j].ID }
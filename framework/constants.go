@@ -10,8 +10,55 @@ const (
 	DefaultFrameworkStorage = "file:/tmp/gonsumer.json"
 )
 
+const (
+	// SchedulerAPIDriver talks to Mesos master with libprocess based scheduler driver.
+	SchedulerAPIDriver = "driver"
+	// SchedulerAPIHTTP talks to Mesos master with v1 HTTP Scheduler API.
+	SchedulerAPIHTTP = "http"
+
+	DefaultSchedulerAPI = SchedulerAPIDriver
+)
+
+const (
+	DefaultConsumerCpus = 0.5
+	DefaultConsumerMem  = 256
+	DefaultConsumerDisk = 0
+)
+
+// DefaultUnreachableTimeout is how long to wait for an unreachable consumer before launching a replacement.
+const DefaultUnreachableTimeout = 5 * time.Minute
+
+// DefaultFileStorageBackups is how many previous versions of cluster state file storage keeps.
+const DefaultFileStorageBackups = 3
+
+// DefaultKillGracePeriod is how long a killed consumer may take to commit offsets and leave its consumer group.
+const DefaultKillGracePeriod = 30 * time.Second
+
+const DefaultRefuseSeconds = 5.0
+
+// suppressedRefuseSeconds is how long offers are declined for once every consumer is placed.
+// Filters are cleared as soon as offers are revived.
+const suppressedRefuseSeconds = 3600.0
+
+const (
+	DefaultRestartBackoff    = 5 * time.Second
+	DefaultRestartBackoffMax = 5 * time.Minute
+	DefaultRestartResetAfter = 10 * time.Minute
+)
+
 const (
 	ParamGroupID          = "group-id"
 	ParamSubscription     = "subscription"
 	ParamBootstrapBrokers = "bootstrap-brokers"
+	ParamCpus             = "cpus"
+	ParamMem              = "mem"
+	ParamDisk             = "disk"
+	ParamForce            = "force"
+	ParamOptions          = "options"
+	ParamInstances        = "instances"
+	ParamConstraints      = "constraints"
+	ParamPorts            = "ports"
+
+	ParamUnreachableTimeout = "unreachable-timeout"
+	ParamKillGracePeriod    = "kill-grace-period"
 )
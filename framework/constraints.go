@@ -0,0 +1,242 @@
+package framework
+
+import (
+	"fmt"
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ConstraintUnique allows at most one consumer of a group per attribute value.
+	ConstraintUnique = "unique"
+	// ConstraintCluster places all consumers of a group onto the same attribute value.
+	ConstraintCluster = "cluster"
+	// ConstraintLike requires the attribute value to match a regular expression.
+	ConstraintLike = "like"
+	// ConstraintUnlike requires the attribute value not to match a regular expression.
+	ConstraintUnlike = "unlike"
+	// ConstraintGroupBy spreads consumers of a group evenly across a given number of attribute values.
+	ConstraintGroupBy = "groupBy"
+)
+
+// hostnameAttribute refers to the offer hostname rather than to an agent attribute.
+const hostnameAttribute = "hostname"
+
+// constraintStart matches the beginning of a constraint, so commas followed by anything else belong to
+// a regular expression.
+var constraintStart = regexp.MustCompile(`^[A-Za-z0-9_.-]+=`)
+
+// Constraint restricts placement of consumers based on offer attributes.
+// Its text form is attribute=operator[:value], e.g. hostname=unique or rack=like:r1.*
+type Constraint struct {
+	Attribute string
+	Operator  string
+	Value     string
+
+	regex   *regexp.Regexp
+	groupBy int
+}
+
+// ParseConstraint parses a single constraint in form attribute=operator[:value].
+func ParseConstraint(rawConstraint string) (*Constraint, error) {
+	kv := strings.SplitN(rawConstraint, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return nil, fmt.Errorf("Invalid constraint %s, expected attribute=operator[:value]", rawConstraint)
+	}
+
+	constraint := &Constraint{Attribute: kv[0]}
+	operatorValue := strings.SplitN(kv[1], ":", 2)
+	constraint.Operator = operatorValue[0]
+	if len(operatorValue) == 2 {
+		constraint.Value = operatorValue[1]
+	}
+
+	switch constraint.Operator {
+	case ConstraintUnique:
+		if constraint.Value != "" {
+			return nil, fmt.Errorf("Invalid constraint %s, %s does not accept a value", rawConstraint, constraint.Operator)
+		}
+	case ConstraintCluster:
+	case ConstraintLike, ConstraintUnlike:
+		if constraint.Value == "" {
+			return nil, fmt.Errorf("Invalid constraint %s, %s requires a regular expression", rawConstraint, constraint.Operator)
+		}
+
+		regex, err := regexp.Compile("^(?:" + constraint.Value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("Invalid constraint %s: %s", rawConstraint, err)
+		}
+		constraint.regex = regex
+	case ConstraintGroupBy:
+		if constraint.Value != "" {
+			groupBy, err := strconv.Atoi(constraint.Value)
+			if err != nil || groupBy <= 0 {
+				return nil, fmt.Errorf("Invalid constraint %s, %s requires a positive number", rawConstraint, constraint.Operator)
+			}
+			constraint.groupBy = groupBy
+		}
+	default:
+		return nil, fmt.Errorf("Invalid constraint %s, unknown operator %s", rawConstraint, constraint.Operator)
+	}
+
+	return constraint, nil
+}
+
+// ParseConstraints parses comma separated constraints. A comma separates constraints only if it is followed
+// by attribute=, so regular expressions may contain commas, e.g. hostname=like:a{1,3},rack=unique
+func ParseConstraints(rawConstraints string) ([]*Constraint, error) {
+	constraints := make([]*Constraint, 0)
+	if rawConstraints == "" {
+		return constraints, nil
+	}
+
+	for _, rawConstraint := range splitConstraints(rawConstraints) {
+		constraint, err := ParseConstraint(rawConstraint)
+		if err != nil {
+			return nil, err
+		}
+
+		constraints = append(constraints, constraint)
+	}
+
+	return constraints, nil
+}
+
+func splitConstraints(rawConstraints string) []string {
+	split := make([]string, 0)
+	start := 0
+	for i := 0; i < len(rawConstraints); i++ {
+		if rawConstraints[i] == ',' && constraintStart.MatchString(rawConstraints[i+1:]) {
+			split = append(split, rawConstraints[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(split, rawConstraints[start:])
+}
+
+// FormatConstraints formats constraints to a form accepted by ParseConstraints.
+func FormatConstraints(constraints []*Constraint) string {
+	rawConstraints := make([]string, 0, len(constraints))
+	for _, constraint := range constraints {
+		rawConstraints = append(rawConstraints, constraint.String())
+	}
+
+	return strings.Join(rawConstraints, ",")
+}
+
+func (c *Constraint) String() string {
+	if c.Value == "" {
+		return fmt.Sprintf("%s=%s", c.Attribute, c.Operator)
+	}
+
+	return fmt.Sprintf("%s=%s:%s", c.Attribute, c.Operator, c.Value)
+}
+
+func (c *Constraint) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Constraint) UnmarshalText(text []byte) error {
+	constraint, err := ParseConstraint(string(text))
+	if err != nil {
+		return err
+	}
+
+	*c = *constraint
+	return nil
+}
+
+// Matches checks whether an attribute value satisfies this constraint given values of already placed consumers.
+func (c *Constraint) Matches(value string, placed []string) bool {
+	switch c.Operator {
+	case ConstraintUnique:
+		for _, placedValue := range placed {
+			if placedValue == value {
+				return false
+			}
+		}
+		return true
+	case ConstraintCluster:
+		if c.Value != "" {
+			return value == c.Value
+		}
+		return len(placed) == 0 || placed[0] == value
+	case ConstraintLike:
+		return c.regex.MatchString(value)
+	case ConstraintUnlike:
+		return !c.regex.MatchString(value)
+	case ConstraintGroupBy:
+		counts := make(map[string]int)
+		for _, placedValue := range placed {
+			counts[placedValue]++
+		}
+
+		// until all groupBy values are taken only unused values are accepted
+		minCount := 0
+		if len(counts) >= c.groupBy {
+			minCount = len(placed)
+			for _, count := range counts {
+				if count < minCount {
+					minCount = count
+				}
+			}
+		}
+
+		return counts[value] <= minCount
+	default:
+		return false
+	}
+}
+
+// constraintsMatch returns an empty string if a consumer of a given group may be placed onto a given offer,
+// otherwise a decline reason.
+func constraintsMatch(group *Group, offer *mesos.Offer) string {
+	if len(group.Constraints) == 0 {
+		return ""
+	}
+
+	attributes := offerAttributes(offer)
+	for _, constraint := range group.Constraints {
+		value, ok := attributes[constraint.Attribute]
+		if !ok {
+			return fmt.Sprintf("no %s attribute", constraint.Attribute)
+		}
+
+		placed := make([]string, 0)
+		for _, consumer := range group.Consumers {
+			if consumer.TaskID == "" {
+				continue
+			}
+
+			if placedValue, ok := consumer.Attributes[constraint.Attribute]; ok {
+				placed = append(placed, placedValue)
+			}
+		}
+
+		if !constraint.Matches(value, placed) {
+			return fmt.Sprintf("%s doesn't match %s", value, constraint)
+		}
+	}
+
+	return ""
+}
+
+// offerAttributes returns text and scalar attributes of a given offer along with its hostname.
+func offerAttributes(offer *mesos.Offer) map[string]string {
+	attributes := map[string]string{
+		hostnameAttribute: offer.GetHostname(),
+	}
+
+	for _, attribute := range offer.GetAttributes() {
+		if attribute.GetText() != nil {
+			attributes[attribute.GetName()] = attribute.GetText().GetValue()
+		} else if attribute.GetScalar() != nil {
+			attributes[attribute.GetName()] = strconv.FormatFloat(attribute.GetScalar().GetValue(), 'f', -1, 64)
+		}
+	}
+
+	return attributes
+}
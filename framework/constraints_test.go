@@ -0,0 +1,119 @@
+package framework
+
+import (
+	"encoding/json"
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseConstraints(t *testing.T) {
+	constraints, err := ParseConstraints("hostname=unique,rack=like:r1.*,zone=groupBy:3,dc=unlike:eu-west,az=cluster")
+	require.Nil(t, err)
+	require.Len(t, constraints, 5)
+	assert.Equal(t, &Constraint{Attribute: "hostname", Operator: ConstraintUnique}, constraints[0])
+	assert.Equal(t, "rack", constraints[1].Attribute)
+	assert.Equal(t, ConstraintLike, constraints[1].Operator)
+	assert.Equal(t, "r1.*", constraints[1].Value)
+	assert.Equal(t, 3, constraints[2].groupBy)
+	assert.Equal(t, "hostname=unique,rack=like:r1.*,zone=groupBy:3,dc=unlike:eu-west,az=cluster", FormatConstraints(constraints))
+
+	// commas not followed by an attribute belong to a regular expression
+	constraints, err = ParseConstraints("hostname=like:a{1,3},rack=unlike:r(1|2),zone=like:z1,z2,az=unique")
+	require.Nil(t, err)
+	require.Len(t, constraints, 4)
+	assert.Equal(t, "a{1,3}", constraints[0].Value)
+	assert.True(t, constraints[0].regex.MatchString("aaa"))
+	assert.False(t, constraints[0].regex.MatchString("aaaa"))
+	assert.Equal(t, "r(1|2)", constraints[1].Value)
+	assert.Equal(t, "z1,z2", constraints[2].Value)
+	assert.Equal(t, "az", constraints[3].Attribute)
+	assert.Equal(t, "hostname=like:a{1,3},rack=unlike:r(1|2),zone=like:z1,z2,az=unique", FormatConstraints(constraints))
+
+	constraints, err = ParseConstraints("")
+	assert.Nil(t, err)
+	assert.Empty(t, constraints)
+
+	for _, invalid := range []string{"hostname", "=unique", "hostname=uniq", "hostname=unique:1", "rack=like", "rack=like:[", "zone=groupBy:zero", "zone=groupBy:-1"} {
+		_, err = ParseConstraints(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestConstraintMatches(t *testing.T) {
+	constraint, err := ParseConstraint("hostname=unique")
+	require.Nil(t, err)
+	assert.True(t, constraint.Matches("a", nil))
+	assert.True(t, constraint.Matches("a", []string{"b"}))
+	assert.False(t, constraint.Matches("a", []string{"b", "a"}))
+
+	constraint, err = ParseConstraint("rack=like:r1.*")
+	require.Nil(t, err)
+	assert.True(t, constraint.Matches("r1", nil))
+	assert.True(t, constraint.Matches("r10", nil))
+	assert.False(t, constraint.Matches("ar1", nil))
+
+	constraint, err = ParseConstraint("dc=unlike:eu-west")
+	require.Nil(t, err)
+	assert.False(t, constraint.Matches("eu-west", nil))
+	assert.True(t, constraint.Matches("eu-west-1", nil))
+
+	constraint, err = ParseConstraint("az=cluster:a")
+	require.Nil(t, err)
+	assert.True(t, constraint.Matches("a", nil))
+	assert.False(t, constraint.Matches("b", nil))
+
+	constraint, err = ParseConstraint("az=cluster")
+	require.Nil(t, err)
+	assert.True(t, constraint.Matches("b", nil))
+	assert.True(t, constraint.Matches("b", []string{"b"}))
+	assert.False(t, constraint.Matches("a", []string{"b"}))
+
+	constraint, err = ParseConstraint("zone=groupBy:2")
+	require.Nil(t, err)
+	assert.True(t, constraint.Matches("a", nil))
+	assert.False(t, constraint.Matches("a", []string{"a"}))
+	assert.True(t, constraint.Matches("b", []string{"a"}))
+	assert.True(t, constraint.Matches("a", []string{"a", "b"}))
+	assert.False(t, constraint.Matches("a", []string{"a", "b", "a"}))
+}
+
+func TestConstraintJSON(t *testing.T) {
+	group := NewGroup("foo")
+	var err error
+	group.Constraints, err = ParseConstraints("rack=like:r1.*")
+	require.Nil(t, err)
+
+	data, err := json.Marshal(group)
+	require.Nil(t, err)
+	assert.Contains(t, string(data), `"constraints":["rack=like:r1.*"]`)
+
+	loadedGroup := new(Group)
+	require.Nil(t, json.Unmarshal(data, loadedGroup))
+	require.Len(t, loadedGroup.Constraints, 1)
+	assert.True(t, loadedGroup.Constraints[0].Matches("r1", nil))
+}
+
+func TestConstraintsMatch(t *testing.T) {
+	group := newTestGroup("foo")
+	var err error
+	group.Constraints, err = ParseConstraints("rack=like:r1")
+	require.Nil(t, err)
+
+	offer := newTestOffer("1", 4, 4096)
+	assert.Equal(t, "no rack attribute", constraintsMatch(group, offer))
+
+	offer.Attributes = []*mesos.Attribute{
+		{
+			Name: proto.String("rack"),
+			Type: mesos.Value_TEXT.Enum(),
+			Text: &mesos.Value_Text{Value: proto.String("r2")},
+		},
+	}
+	assert.Equal(t, "r2 doesn't match rack=like:r1", constraintsMatch(group, offer))
+
+	offer.Attributes[0].Text.Value = proto.String("r1")
+	assert.Equal(t, "", constraintsMatch(group, offer))
+}
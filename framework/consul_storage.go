@@ -0,0 +1,300 @@
+package framework
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/hashicorp/consul/api"
+	"github.com/yanzay/log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	consulDefaultPrefix = "gonsumer"
+	consulStateKey      = "cluster"
+	consulWaitTime      = 5 * time.Minute
+	consulRetryDelay    = 100 * time.Millisecond
+	consulRetryMaxDelay = 5 * time.Second
+)
+
+// ConsulStorage keeps cluster state under a key of Consul's KV store. Writes are check-and-set against the modify
+// index read last, so a write racing with another scheduler instance fails with ErrStorageConflict.
+type ConsulStorage struct {
+	address string
+	key     string
+	client  *api.Client
+
+	lock sync.Mutex
+	// index is the modify index of the key read or written last, zero if the key does not exist and noVersion until
+	// the key is read.
+	index int64
+}
+
+// NewConsulStorage creates a storage from a connect string in form host:port[/prefix][?options].
+// Options token and dc set an ACL token and a datacenter other than the agent's one, e.g.
+// consul1:8500/mesos/gonsumer?token=secret&dc=dc2
+func NewConsulStorage(consul string) (*ConsulStorage, error) {
+	config, prefix, err := parseConsulConnect(consul)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := api.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConsulStorage{
+		address: config.Address,
+		key:     prefix + "/" + consulStateKey,
+		client:  client,
+		index:   noVersion,
+	}, nil
+}
+
+func (cs *ConsulStorage) Save(contents []byte) error {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	if cs.index == noVersion {
+		// nothing was read yet, so there is nothing to compare with
+		_, err := cs.get()
+		if err != nil {
+			return err
+		}
+	}
+
+	// a transaction, unlike a plain check-and-set write, returns the modify index the write results in
+	ops := api.TxnOps{
+		&api.TxnOp{KV: &api.KVTxnOp{
+			Verb:  api.KVCAS,
+			Key:   cs.key,
+			Value: contents,
+			Index: uint64(cs.index),
+		}},
+	}
+	written, response, _, err := cs.client.Txn().Txn(ops, nil)
+	if err != nil {
+		// the write may have been applied or not
+		if cs.applied(contents) {
+			return nil
+		}
+		return err
+	}
+
+	if !written {
+		return cs.txnError(response.Errors)
+	}
+
+	cs.index = int64(response.Results[0].KV.ModifyIndex)
+	return nil
+}
+
+func (cs *ConsulStorage) Load() ([]byte, error) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	contents, err := cs.get()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(contents) == 0 {
+		return nil, ErrStorageUninitialized
+	}
+
+	return contents, nil
+}
+
+// Watch sends current contents of the key and then its contents after every change until stop is closed.
+// Changes are followed with blocking queries. Watching does not affect the index compared by Save.
+func (cs *ConsulStorage) Watch(stop <-chan struct{}) (<-chan []byte, error) {
+	pair, meta, err := cs.client.KV().Get(cs.key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(chan []byte)
+	go func() {
+		defer close(changes)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		contents := pairValue(pair)
+		select {
+		case changes <- contents:
+		case <-stop:
+			return
+		}
+
+		index := meta.LastIndex
+		delay := consulRetryDelay
+		for {
+			options := &api.QueryOptions{WaitIndex: index, WaitTime: consulWaitTime}
+			pair, meta, err := cs.client.KV().Get(cs.key, options.WithContext(ctx))
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				log.Warningf("Failed to watch %s: %s, retrying in %s", cs, err, delay)
+				select {
+				case <-time.After(delay):
+					delay = nextConsulRetryDelay(delay)
+					continue
+				case <-stop:
+					return
+				}
+			}
+			delay = consulRetryDelay
+
+			if meta.LastIndex < index {
+				// the index went backwards, e.g. Consul state was restored from a snapshot
+				index = 0
+				continue
+			}
+			index = meta.LastIndex
+
+			// blocking queries may return without the key being changed
+			if string(pairValue(pair)) == string(contents) {
+				continue
+			}
+			contents = pairValue(pair)
+
+			select {
+			case changes <- contents:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+func (cs *ConsulStorage) String() string {
+	return fmt.Sprintf("%s/%s", cs.address, cs.key)
+}
+
+// get reads the key remembering its modify index. Must be called with lock held.
+func (cs *ConsulStorage) get() ([]byte, error) {
+	pair, _, err := cs.client.KV().Get(cs.key, &api.QueryOptions{RequireConsistent: true})
+	if err != nil {
+		return nil, err
+	}
+
+	if pair == nil {
+		cs.index = 0
+		return nil, nil
+	}
+
+	cs.index = int64(pair.ModifyIndex)
+	return pair.Value, nil
+}
+
+// applied checks whether a write which result is unknown was applied, i.e. the key was modified since it was read
+// and holds given contents, and takes the modify index of the write if so. Otherwise the index is kept, so a write
+// made by another instance in the meantime fails the next save. Must be called with lock held.
+func (cs *ConsulStorage) applied(contents []byte) bool {
+	pair, _, err := cs.client.KV().Get(cs.key, &api.QueryOptions{RequireConsistent: true})
+	if err != nil || pair == nil || int64(pair.ModifyIndex) == cs.index || !bytes.Equal(pair.Value, contents) {
+		return false
+	}
+
+	cs.index = int64(pair.ModifyIndex)
+	return true
+}
+
+// txnError returns ErrStorageConflict if a failed write was rejected because the key was modified since it was read,
+// and given transaction errors otherwise, e.g. if the token is not allowed to write the key. Must be called with
+// lock held.
+func (cs *ConsulStorage) txnError(txnErrors api.TxnErrors) error {
+	pair, _, err := cs.client.KV().Get(cs.key, &api.QueryOptions{RequireConsistent: true})
+	if err != nil {
+		return err
+	}
+
+	var index int64
+	if pair != nil {
+		index = int64(pair.ModifyIndex)
+	}
+
+	if index != cs.index {
+		return ErrStorageConflict
+	}
+
+	messages := make([]string, 0, len(txnErrors))
+	for _, txnError := range txnErrors {
+		messages = append(messages, txnError.What)
+	}
+	return fmt.Errorf("Failed to write %s: %s", cs, strings.Join(messages, ", "))
+}
+
+func pairValue(pair *api.KVPair) []byte {
+	if pair == nil {
+		return nil
+	}
+
+	return pair.Value
+}
+
+func nextConsulRetryDelay(delay time.Duration) time.Duration {
+	delay *= 2
+	if delay > consulRetryMaxDelay {
+		delay = consulRetryMaxDelay
+	}
+
+	return delay
+}
+
+// parseConsulConnect splits a Consul connect string to client configuration and key prefix.
+func parseConsulConnect(consul string) (*api.Config, string, error) {
+	rawOptions := ""
+	if optionsIdx := strings.Index(consul, "?"); optionsIdx != -1 {
+		consul, rawOptions = consul[:optionsIdx], consul[optionsIdx+1:]
+	}
+
+	prefix := consulDefaultPrefix
+	if prefixIdx := strings.Index(consul, "/"); prefixIdx != -1 {
+		consul, prefix = consul[:prefixIdx], strings.Trim(consul[prefixIdx:], "/")
+	}
+
+	if consul == "" {
+		return nil, "", ErrNoConsulAddress
+	}
+
+	if prefix == "" {
+		return nil, "", ErrEmptyConsulPrefix
+	}
+
+	options, err := url.ParseQuery(rawOptions)
+	if err != nil {
+		return nil, "", err
+	}
+
+	config := api.DefaultConfig()
+	config.Address = consul
+	for name := range options {
+		switch name {
+		case "token":
+			config.Token = options.Get(name)
+		case "dc":
+			config.Datacenter = options.Get(name)
+		default:
+			return nil, "", fmt.Errorf("Unknown consul option %s", name)
+		}
+	}
+
+	return config, prefix, nil
+}
@@ -0,0 +1,333 @@
+package framework
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type consulKVEntry struct {
+	Key         string
+	Value       []byte
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// consulKVStub implements the part of Consul's HTTP KV API used by ConsulStorage: reads, blocking reads and
+// check-and-set writes in transactions. Requests with a token other than the expected one are denied.
+type consulKVStub struct {
+	token      string
+	datacenter string
+	// failWrites is how many following writes are applied but answered with an error, as if the response was lost.
+	failWrites int
+	// dropWrites is how many following writes are answered with an error without being applied.
+	dropWrites int
+
+	lock    sync.Mutex
+	index   uint64
+	entries map[string]*consulKVEntry
+	// changed is closed and replaced on every write to wake blocking reads.
+	changed chan struct{}
+}
+
+func newConsulKVStub(token string, datacenter string) *consulKVStub {
+	return &consulKVStub{
+		token:      token,
+		datacenter: datacenter,
+		index:      1,
+		entries:    make(map[string]*consulKVEntry),
+		changed:    make(chan struct{}),
+	}
+}
+
+func (stub *consulKVStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Consul-Token") != stub.token {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+		return
+	}
+
+	if r.URL.Query().Get("dc") != stub.datacenter {
+		http.Error(w, "No path to datacenter", http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Path == "/v1/txn" && r.Method == http.MethodPut {
+		stub.txn(w, r)
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, "/v1/kv/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stub.get(w, r, strings.TrimPrefix(r.URL.Path, "/v1/kv/"))
+}
+
+func (stub *consulKVStub) get(w http.ResponseWriter, r *http.Request, key string) {
+	waitIndex, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+
+	stub.lock.Lock()
+	for waitIndex > 0 && stub.lastIndex(key) <= waitIndex {
+		changed := stub.changed
+		stub.lock.Unlock()
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+		stub.lock.Lock()
+	}
+	defer stub.lock.Unlock()
+
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(stub.lastIndex(key), 10))
+	entry, exists := stub.entries[key]
+	if !exists {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode([]*consulKVEntry{entry})
+}
+
+type consulTxnOp struct {
+	KV struct {
+		Verb  string
+		Key   string
+		Value []byte
+		Index uint64
+	}
+}
+
+type consulTxnError struct {
+	OpIndex int
+	What    string
+}
+
+type consulTxnResponse struct {
+	Results []map[string]*consulKVEntry
+	Errors  []consulTxnError
+}
+
+// txn supports transactions of a single cas operation only.
+func (stub *consulKVStub) txn(w http.ResponseWriter, r *http.Request) {
+	var ops []consulTxnOp
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil || len(ops) != 1 || ops[0].KV.Verb != "cas" {
+		http.Error(w, "Unsupported transaction", http.StatusBadRequest)
+		return
+	}
+	op := ops[0].KV
+
+	stub.lock.Lock()
+	defer stub.lock.Unlock()
+
+	if stub.dropWrites > 0 {
+		stub.dropWrites--
+		http.Error(w, "Connection reset", http.StatusInternalServerError)
+		return
+	}
+
+	entry := stub.set(op.Key, op.Value, op.Index)
+	if stub.failWrite(w) {
+		return
+	}
+
+	if entry == nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(consulTxnResponse{
+			Errors: []consulTxnError{{What: fmt.Sprintf("failed to set key %q, index is stale", op.Key)}},
+		})
+		return
+	}
+
+	// like Consul, results of writes do not carry values
+	result := *entry
+	result.Value = nil
+	json.NewEncoder(w).Encode(consulTxnResponse{
+		Results: []map[string]*consulKVEntry{{"KV": &result}},
+	})
+}
+
+// set writes a key unless cas does not match its modify index, zero meaning the key must not exist. It returns
+// the entry written or nil. Must be called with lock held.
+func (stub *consulKVStub) set(key string, value []byte, cas uint64) *consulKVEntry {
+	entry, exists := stub.entries[key]
+	if cas == 0 && exists || cas > 0 && (!exists || entry.ModifyIndex != cas) {
+		return nil
+	}
+
+	stub.index++
+	if !exists {
+		entry = &consulKVEntry{Key: key, CreateIndex: stub.index}
+		stub.entries[key] = entry
+	}
+	entry.Value = value
+	entry.ModifyIndex = stub.index
+
+	close(stub.changed)
+	stub.changed = make(chan struct{})
+	return entry
+}
+
+// failWrite answers with an error if the write should fail. Must be called with lock held.
+func (stub *consulKVStub) failWrite(w http.ResponseWriter) bool {
+	if stub.failWrites == 0 {
+		return false
+	}
+
+	stub.failWrites--
+	http.Error(w, "Connection reset", http.StatusInternalServerError)
+	return true
+}
+
+// lastIndex returns the index blocking reads of a given key wait on. Must be called with lock held.
+func (stub *consulKVStub) lastIndex(key string) uint64 {
+	if entry, exists := stub.entries[key]; exists {
+		return entry.ModifyIndex
+	}
+
+	return stub.index
+}
+
+func TestConsulStorage(t *testing.T) {
+	stub := newConsulKVStub("secret", "dc2")
+	server := httptest.NewServer(stub)
+	defer server.Close()
+	address := strings.TrimPrefix(server.URL, "http://")
+
+	contents := "hello world"
+	storage, err := NewStorage("consul:" + address + "/test?token=secret&dc=dc2")
+	require.Nil(t, err)
+	consulStorage := storage.(*ConsulStorage)
+	assert.Equal(t, address+"/test/cluster", consulStorage.String())
+
+	_, err = storage.Load()
+	assert.Equal(t, ErrStorageUninitialized, err)
+
+	require.Nil(t, storage.Save([]byte(contents)))
+	loadedContents, err := storage.Load()
+	require.Nil(t, err)
+	assert.Equal(t, contents, string(loadedContents))
+
+	stop := make(chan struct{})
+	defer close(stop)
+	changes, err := consulStorage.Watch(stop)
+	require.Nil(t, err)
+	assert.Equal(t, contents, string(awaitChange(t, changes)))
+
+	// a write made by another instance in the meantime is not overwritten
+	other, err := NewConsulStorage(address + "/test?token=secret&dc=dc2")
+	require.Nil(t, err)
+	_, err = other.Load()
+	require.Nil(t, err)
+	require.Nil(t, other.Save([]byte("other")))
+	assert.Equal(t, "other", string(awaitChange(t, changes)))
+	assert.Equal(t, ErrStorageConflict, storage.Save([]byte("stale")))
+
+	// writing is possible again once the new index is read
+	loadedContents, err = storage.Load()
+	require.Nil(t, err)
+	assert.Equal(t, "other", string(loadedContents))
+	require.Nil(t, storage.Save([]byte(contents)))
+	require.Nil(t, storage.Save([]byte("again")))
+	assert.Equal(t, "again", string(awaitLastChange(t, changes)))
+
+	// writing identical contents is a modification as well
+	_, err = other.Load()
+	require.Nil(t, err)
+	require.Nil(t, other.Save([]byte("again")))
+	assert.Equal(t, ErrStorageConflict, storage.Save([]byte("stale")))
+	_, err = storage.Load()
+	require.Nil(t, err)
+
+	// a write applied without a response is recognized and does not prevent following writes
+	stub.lock.Lock()
+	stub.failWrites = 1
+	stub.lock.Unlock()
+	require.Nil(t, storage.Save([]byte("lost")))
+	require.Nil(t, storage.Save([]byte("again")))
+	loadedContents, err = other.Load()
+	require.Nil(t, err)
+	assert.Equal(t, "again", string(loadedContents))
+
+	// a failed write does not let a write made by another instance in the meantime be overwritten
+	stub.lock.Lock()
+	stub.dropWrites = 1
+	stub.lock.Unlock()
+	assert.NotNil(t, storage.Save([]byte("dropped")))
+	require.Nil(t, other.Save([]byte("other")))
+	assert.Equal(t, ErrStorageConflict, storage.Save([]byte("stale")))
+	loadedContents, err = storage.Load()
+	require.Nil(t, err)
+	assert.Equal(t, "other", string(loadedContents))
+	require.Nil(t, storage.Save([]byte("again")))
+
+	// keys under another prefix are independent
+	unrelated, err := NewConsulStorage(address + "/unrelated?token=secret&dc=dc2")
+	require.Nil(t, err)
+	_, err = unrelated.Load()
+	assert.Equal(t, ErrStorageUninitialized, err)
+	require.Nil(t, unrelated.Save([]byte("unrelated")))
+	loadedContents, err = storage.Load()
+	require.Nil(t, err)
+	assert.Equal(t, "again", string(loadedContents))
+
+	// requests are sent with the token and to the datacenter given
+	denied, err := NewConsulStorage(address + "/test?token=wrong&dc=dc2")
+	require.Nil(t, err)
+	_, err = denied.Load()
+	assert.NotNil(t, err)
+
+	otherDatacenter, err := NewConsulStorage(address + "/test?token=secret")
+	require.Nil(t, err)
+	_, err = otherDatacenter.Load()
+	assert.NotNil(t, err)
+}
+
+// awaitLastChange returns the latest change once no more changes arrive for a while.
+func awaitLastChange(t *testing.T, changes <-chan []byte) []byte {
+	change := awaitChange(t, changes)
+	for {
+		select {
+		case change = <-changes:
+		case <-time.After(100 * time.Millisecond):
+			return change
+		}
+	}
+}
+
+func TestParseConsulConnect(t *testing.T) {
+	config, prefix, err := parseConsulConnect("consul1:8500")
+	require.Nil(t, err)
+	assert.Equal(t, "consul1:8500", config.Address)
+	assert.Equal(t, consulDefaultPrefix, prefix)
+
+	config, prefix, err = parseConsulConnect("consul1:8500/mesos/gonsumer/?token=secret&dc=dc2")
+	require.Nil(t, err)
+	assert.Equal(t, "consul1:8500", config.Address)
+	assert.Equal(t, "mesos/gonsumer", prefix)
+	assert.Equal(t, "secret", config.Token)
+	assert.Equal(t, "dc2", config.Datacenter)
+
+	_, _, err = parseConsulConnect("/gonsumer")
+	assert.Equal(t, ErrNoConsulAddress, err)
+
+	_, _, err = parseConsulConnect("consul1:8500/")
+	assert.Equal(t, ErrEmptyConsulPrefix, err)
+
+	_, _, err = parseConsulConnect("consul1:8500?wait=5m")
+	assert.NotNil(t, err)
+}
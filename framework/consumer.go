@@ -0,0 +1,282 @@
+package framework
+
+import (
+	"fmt"
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	"strconv"
+	"time"
+)
+
+type ConsumerState string
+
+const (
+	ConsumerStateStopped     ConsumerState = "stopped"
+	ConsumerStatePending     ConsumerState = "pending"
+	ConsumerStateStaging     ConsumerState = "staging"
+	ConsumerStateRunning     ConsumerState = "running"
+	ConsumerStateStopping    ConsumerState = "stopping"
+	ConsumerStateFailed      ConsumerState = "failed"
+	ConsumerStateLost        ConsumerState = "lost"
+	ConsumerStateUnreachable ConsumerState = "unreachable"
+	ConsumerStateFinished    ConsumerState = "finished"
+)
+
+// consumerTransitions lists states a consumer is allowed to move to from each state.
+var consumerTransitions = map[ConsumerState][]ConsumerState{
+	ConsumerStateStopped:     {ConsumerStatePending},
+	ConsumerStatePending:     {ConsumerStateStaging, ConsumerStateStopped},
+	ConsumerStateStaging:     {ConsumerStatePending, ConsumerStateRunning, ConsumerStateFailed, ConsumerStateLost, ConsumerStateUnreachable, ConsumerStateFinished, ConsumerStateStopping, ConsumerStateStopped},
+	ConsumerStateRunning:     {ConsumerStatePending, ConsumerStateFailed, ConsumerStateLost, ConsumerStateUnreachable, ConsumerStateFinished, ConsumerStateStopping, ConsumerStateStopped},
+	ConsumerStateStopping:    {ConsumerStateStopped},
+	ConsumerStateFailed:      {ConsumerStatePending, ConsumerStateStopped},
+	ConsumerStateLost:        {ConsumerStatePending, ConsumerStateRunning, ConsumerStateFailed, ConsumerStateFinished, ConsumerStateStopped},
+	ConsumerStateUnreachable: {ConsumerStatePending, ConsumerStateRunning, ConsumerStateFailed, ConsumerStateLost, ConsumerStateFinished, ConsumerStateStopping, ConsumerStateStopped},
+	ConsumerStateFinished:    {ConsumerStatePending, ConsumerStateStopped},
+}
+
+// maxConsumerEvents is how many most recent events each consumer keeps.
+const maxConsumerEvents = 10
+
+// ConsumerEvent records why a consumer has changed outside of regular task status updates.
+type ConsumerEvent struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+type Consumer struct {
+	ID    string        `json:"id"`
+	State ConsumerState `json:"state"`
+
+	TaskID     string    `json:"task_id,omitempty"`
+	SlaveID    string    `json:"slave_id,omitempty"`
+	Hostname   string    `json:"hostname,omitempty"`
+	LaunchTime time.Time `json:"launch_time"`
+	// Ports allocated for the current task of this consumer.
+	Ports []uint64 `json:"ports,omitempty"`
+	// Attributes of the offer this consumer was placed onto, used to evaluate placement constraints.
+	Attributes map[string]string `json:"attributes,omitempty"`
+
+	// Message and Reason of the last received task status.
+	Message string `json:"message,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+
+	// Failures is the number of consecutive task failures of this consumer.
+	Failures int `json:"failures,omitempty"`
+	// RunningTime is when the current task of this consumer became running.
+	RunningTime time.Time `json:"running_time"`
+	// RestartTime is when this consumer may be relaunched after a failure.
+	RestartTime time.Time `json:"restart_time"`
+	// UnreachableTime is when the task of this consumer became unreachable.
+	UnreachableTime time.Time `json:"unreachable_time"`
+	// KillTime is when the task of this consumer was asked to stop, zero unless it is being killed.
+	KillTime time.Time `json:"kill_time"`
+
+	// Events are the most recent notable changes of this consumer, oldest first.
+	Events []*ConsumerEvent `json:"events,omitempty"`
+
+	// Restart is set when this consumer awaits a rolling restart to pick up group configuration changes.
+	Restart bool `json:"restart,omitempty"`
+	// Restarting is set from the moment this consumer is killed for a restart until its replacement is running.
+	Restarting bool `json:"restarting,omitempty"`
+}
+
+func NewConsumer(id string) *Consumer {
+	return &Consumer{
+		ID:    id,
+		State: ConsumerStatePending,
+	}
+}
+
+// Transition moves this consumer to a given state. Returns an error if such transition is not allowed.
+// Transition to the current state is a no-op.
+func (c *Consumer) Transition(state ConsumerState) error {
+	if c.State == state {
+		return nil
+	}
+
+	for _, allowed := range consumerTransitions[c.State] {
+		if allowed == state {
+			c.State = state
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Invalid consumer %s state transition: %s -> %s", c.ID, c.State, state)
+}
+
+// Launch assigns a task placed on a given offer to this consumer and moves it to staging state.
+func (c *Consumer) Launch(taskID string, offer *mesos.Offer) error {
+	err := c.Transition(ConsumerStateStaging)
+	if err != nil {
+		return err
+	}
+
+	c.TaskID = taskID
+	c.SlaveID = offer.GetSlaveId().GetValue()
+	c.Hostname = offer.GetHostname()
+	c.LaunchTime = time.Now()
+	c.Attributes = offerAttributes(offer)
+	c.Message = ""
+	c.Reason = ""
+	return nil
+}
+
+// Reset clears task assignment of this consumer.
+func (c *Consumer) Reset() {
+	c.TaskID = ""
+	c.SlaveID = ""
+	c.Hostname = ""
+	c.Attributes = nil
+	c.Ports = nil
+	c.KillTime = time.Time{}
+}
+
+// Update applies a given task status to this consumer.
+func (c *Consumer) Update(status *mesos.TaskStatus) error {
+	c.Message = status.GetMessage()
+	c.Reason = ""
+	if status.Reason != nil {
+		c.Reason = status.GetReason().String()
+	}
+
+	if c.State == ConsumerStateStopping || c.State == ConsumerStateStopped {
+		// the task is committing offsets and leaving the consumer group, just wait for it to terminate
+		if isTerminal(status.GetState()) {
+			c.Reset()
+			return c.Transition(ConsumerStateStopped)
+		}
+		return nil
+	}
+
+	if c.Restarting && c.State == ConsumerStateRunning && isTerminal(status.GetState()) {
+		// the task was killed for a restart, relaunch it with the new configuration
+		c.Reset()
+		return c.Transition(ConsumerStatePending)
+	}
+
+	state, ok := consumerStateFor(status.GetState())
+	if !ok {
+		return nil
+	}
+
+	previousState := c.State
+	err := c.Transition(state)
+	if err != nil {
+		return err
+	}
+
+	if state == ConsumerStateRunning && previousState != ConsumerStateRunning {
+		c.RunningTime = time.Now()
+	}
+
+	if state == ConsumerStateUnreachable && previousState != ConsumerStateUnreachable {
+		c.UnreachableTime = time.Now()
+	} else if state != ConsumerStateUnreachable {
+		c.UnreachableTime = time.Time{}
+	}
+
+	return nil
+}
+
+// Failed records a task failure of this consumer and schedules its restart according to a given backoff.
+// The failure count is reset first if the task has been running long enough.
+func (c *Consumer) Failed(backoff RestartBackoff, now time.Time) {
+	if !c.RunningTime.IsZero() && now.Sub(c.RunningTime) >= backoff.ResetAfter {
+		c.Failures = 0
+	}
+
+	c.Failures++
+	c.RunningTime = time.Time{}
+	c.RestartTime = now.Add(backoff.delay(c.Failures))
+}
+
+// AddEvent records an event for this consumer, discarding the oldest ones above maxConsumerEvents.
+func (c *Consumer) AddEvent(format string, args ...interface{}) {
+	c.Events = append(c.Events, &ConsumerEvent{
+		Time:    time.Now(),
+		Message: fmt.Sprintf(format, args...),
+	})
+
+	if len(c.Events) > maxConsumerEvents {
+		c.Events = c.Events[len(c.Events)-maxConsumerEvents:]
+	}
+}
+
+// ResetFailures clears failure count and restart schedule of this consumer.
+func (c *Consumer) ResetFailures() {
+	c.Failures = 0
+	c.RestartTime = time.Time{}
+}
+
+// awaitsRestart returns true if this consumer has failed and should be relaunched.
+func (c *Consumer) awaitsRestart() bool {
+	return c.State == ConsumerStateFailed || c.State == ConsumerStateLost
+}
+
+func consumerStateFor(state mesos.TaskState) (ConsumerState, bool) {
+	switch state {
+	case mesos.TaskState_TASK_STAGING, mesos.TaskState_TASK_STARTING:
+		return ConsumerStateStaging, true
+	case mesos.TaskState_TASK_RUNNING:
+		return ConsumerStateRunning, true
+	case mesos.TaskState_TASK_FINISHED:
+		return ConsumerStateFinished, true
+	case mesos.TaskState_TASK_FAILED, mesos.TaskState_TASK_KILLED, mesos.TaskState_TASK_ERROR:
+		return ConsumerStateFailed, true
+	case mesos.TaskState_TASK_LOST, mesos.TaskState_TASK_DROPPED, mesos.TaskState_TASK_GONE,
+		mesos.TaskState_TASK_GONE_BY_OPERATOR, mesos.TaskState_TASK_UNKNOWN:
+		return ConsumerStateLost, true
+	case mesos.TaskState_TASK_UNREACHABLE:
+		return ConsumerStateUnreachable, true
+	default:
+		return "", false
+	}
+}
+
+// isActive returns true if a task in a given state is running or about to run.
+func isActive(state mesos.TaskState) bool {
+	switch state {
+	case mesos.TaskState_TASK_STAGING, mesos.TaskState_TASK_STARTING, mesos.TaskState_TASK_RUNNING:
+		return true
+	default:
+		return false
+	}
+}
+
+func isTerminal(state mesos.TaskState) bool {
+	switch state {
+	case mesos.TaskState_TASK_FINISHED, mesos.TaskState_TASK_FAILED, mesos.TaskState_TASK_KILLED,
+		mesos.TaskState_TASK_ERROR, mesos.TaskState_TASK_LOST, mesos.TaskState_TASK_DROPPED,
+		mesos.TaskState_TASK_GONE, mesos.TaskState_TASK_GONE_BY_OPERATOR:
+		return true
+	default:
+		return false
+	}
+}
+
+// byLaunchTime sorts consumers starting from the ones launched earliest. Consumers without a task go last.
+type byLaunchTime []*Consumer
+
+func (c byLaunchTime) Len() int      { return len(c) }
+func (c byLaunchTime) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byLaunchTime) Less(i, j int) bool {
+	if c[i].TaskID == "" || c[j].TaskID == "" {
+		return c[i].TaskID != "" && c[j].TaskID == ""
+	}
+
+	return c[i].LaunchTime.Before(c[j].LaunchTime)
+}
+
+type byConsumerID []*Consumer
+
+func (c byConsumerID) Len() int      { return len(c) }
+func (c byConsumerID) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byConsumerID) Less(i, j int) bool {
+	left, leftErr := strconv.Atoi(c[i].ID)
+	right, rightErr := strconv.Atoi(c[j].ID)
+	if leftErr != nil || rightErr != nil {
+		return c[i].ID < c[j].ID
+	}
+
+	return left < right
+}
@@ -0,0 +1,126 @@
+package framework
+
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	util "github.com/mesos/mesos-go/mesosutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConsumerTransition(t *testing.T) {
+	consumer := NewConsumer("0")
+	assert.Equal(t, ConsumerStatePending, consumer.State)
+
+	assert.Nil(t, consumer.Transition(ConsumerStatePending)) // no-op
+	assert.NotNil(t, consumer.Transition(ConsumerStateRunning))
+	assert.Equal(t, ConsumerStatePending, consumer.State)
+
+	assert.Nil(t, consumer.Transition(ConsumerStateStaging))
+	assert.Nil(t, consumer.Transition(ConsumerStateRunning))
+	assert.NotNil(t, consumer.Transition(ConsumerStateStaging))
+	assert.Nil(t, consumer.Transition(ConsumerStateFailed))
+	assert.NotNil(t, consumer.Transition(ConsumerStateRunning))
+	assert.Nil(t, consumer.Transition(ConsumerStateStopped))
+	assert.Equal(t, ConsumerStateStopped, consumer.State)
+
+	// unreachable consumer may come back or be replaced, but only a launched one may become unreachable
+	assert.NotNil(t, consumer.Transition(ConsumerStateUnreachable))
+	consumer.State = ConsumerStateRunning
+	assert.Nil(t, consumer.Transition(ConsumerStateUnreachable))
+	assert.Nil(t, consumer.Transition(ConsumerStateRunning))
+	assert.Nil(t, consumer.Transition(ConsumerStateUnreachable))
+	assert.Nil(t, consumer.Transition(ConsumerStatePending))
+}
+
+func TestConsumerLaunch(t *testing.T) {
+	consumer := NewConsumer("0")
+	offer := newTestOffer("1", 1, 1024)
+
+	err := consumer.Launch("task-1", offer)
+	require.Nil(t, err)
+	assert.Equal(t, ConsumerStateStaging, consumer.State)
+	assert.Equal(t, "task-1", consumer.TaskID)
+	assert.Equal(t, "slave-1", consumer.SlaveID)
+	assert.Equal(t, "host-1", consumer.Hostname)
+
+	// running consumer cannot be launched again
+	consumer.State = ConsumerStateRunning
+	assert.NotNil(t, consumer.Launch("task-2", offer))
+	assert.Equal(t, "task-1", consumer.TaskID)
+}
+
+func TestConsumerUpdate(t *testing.T) {
+	consumer := NewConsumer("0")
+	require.Nil(t, consumer.Launch("task-1", newTestOffer("1", 1, 1024)))
+
+	err := consumer.Update(util.NewTaskStatus(util.NewTaskID("task-1"), mesos.TaskState_TASK_RUNNING))
+	assert.Nil(t, err)
+	assert.Equal(t, ConsumerStateRunning, consumer.State)
+
+	status := util.NewTaskStatus(util.NewTaskID("task-1"), mesos.TaskState_TASK_FAILED)
+	status.Message = proto.String("boom!")
+	status.Reason = mesos.TaskStatus_REASON_EXECUTOR_TERMINATED.Enum()
+	err = consumer.Update(status)
+	assert.Nil(t, err)
+	assert.Equal(t, ConsumerStateFailed, consumer.State)
+	assert.Equal(t, "boom!", consumer.Message)
+	assert.Equal(t, "REASON_EXECUTOR_TERMINATED", consumer.Reason)
+	assert.Equal(t, "task-1", consumer.TaskID) // keep last task for diagnostics
+
+	// failed consumer cannot become running without being relaunched
+	err = consumer.Update(util.NewTaskStatus(util.NewTaskID("task-1"), mesos.TaskState_TASK_RUNNING))
+	assert.NotNil(t, err)
+	assert.Equal(t, ConsumerStateFailed, consumer.State)
+
+	// stopped consumer should release its task once it terminates
+	consumer = NewConsumer("1")
+	require.Nil(t, consumer.Launch("task-2", newTestOffer("1", 1, 1024)))
+	require.Nil(t, consumer.Transition(ConsumerStateStopped))
+	err = consumer.Update(util.NewTaskStatus(util.NewTaskID("task-2"), mesos.TaskState_TASK_KILLED))
+	assert.Nil(t, err)
+	assert.Equal(t, ConsumerStateStopped, consumer.State)
+	assert.Empty(t, consumer.TaskID)
+}
+
+func TestConsumerFailedBackoff(t *testing.T) {
+	backoff := RestartBackoff{Delay: time.Second, MaxDelay: 5 * time.Second, ResetAfter: time.Minute}
+	now := time.Now()
+
+	consumer := NewConsumer("0")
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		consumer.Failed(backoff, now)
+		assert.Equal(t, now.Add(expected), consumer.RestartTime)
+	}
+	assert.Equal(t, 5, consumer.Failures)
+
+	// failures count is kept if the task fails soon after becoming running
+	consumer.RunningTime = now
+	consumer.Failed(backoff, now.Add(time.Second))
+	assert.Equal(t, 6, consumer.Failures)
+	assert.True(t, consumer.RunningTime.IsZero())
+
+	// and reset once it has been healthy long enough
+	consumer.RunningTime = now
+	consumer.Failed(backoff, now.Add(time.Minute))
+	assert.Equal(t, 1, consumer.Failures)
+	assert.Equal(t, now.Add(time.Minute+time.Second), consumer.RestartTime)
+
+	consumer.ResetFailures()
+	assert.Equal(t, 0, consumer.Failures)
+	assert.True(t, consumer.RestartTime.IsZero())
+}
+
+func TestConsumerAddEvent(t *testing.T) {
+	consumer := NewConsumer("0")
+	for i := 0; i < maxConsumerEvents+2; i++ {
+		consumer.AddEvent("event %d", i)
+	}
+
+	require.Len(t, consumer.Events, maxConsumerEvents)
+	assert.Equal(t, "event 2", consumer.Events[0].Message)
+	assert.Equal(t, fmt.Sprintf("event %d", maxConsumerEvents+1), consumer.Events[maxConsumerEvents-1].Message)
+}
@@ -7,3 +7,41 @@ var ErrEmptyZPath = errors.New("Specified blank path")
 var ErrUnsupportedStorage = errors.New("Unsupported storage")
 
 var ErrStorageUninitialized = errors.New("Storage is uninitialized")
+
+var ErrGroupNotFound = errors.New("Group does not exist")
+
+var ErrGroupNotStopped = errors.New("Group should be stopped before removing")
+
+var ErrInvalidInstances = errors.New("Number of instances should not be negative")
+
+var ErrNotRegistered = errors.New("Scheduler is not registered with Mesos master")
+
+var ErrLeadershipLost = errors.New("Leadership lost")
+
+var ErrNoLeader = errors.New("Leader is not elected yet")
+
+var ErrLeaderElectionZk = errors.New("Leader election requires ZooKeeper, specify its address or use zk: storage")
+
+var ErrSecretWithoutPrincipal = errors.New("Secret file requires a principal")
+
+var ErrDriverStarted = errors.New("Scheduler driver has already been started")
+
+var ErrDriverNotRunning = errors.New("Scheduler driver is not running")
+
+var ErrNotSubscribed = errors.New("Scheduler driver is not subscribed to Mesos master")
+
+var ErrHTTPMasterZk = errors.New("HTTP scheduler API requires master in form <host>:<port>")
+
+var ErrUnknownSchedulerAPI = errors.New("Unknown scheduler API, expected driver or http")
+
+var ErrStorageCorrupt = errors.New("Stored cluster state is not valid JSON")
+
+var ErrStorageConflict = errors.New("Stored cluster state has been modified by another scheduler instance")
+
+var ErrNoEtcdEndpoints = errors.New("etcd storage requires at least one endpoint")
+
+var ErrEtcdCertWithoutKey = errors.New("etcd client certificate and key must be given together")
+
+var ErrNoConsulAddress = errors.New("Consul storage requires an agent address")
+
+var ErrEmptyConsulPrefix = errors.New("Consul key prefix must not be empty")
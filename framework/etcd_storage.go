@@ -0,0 +1,251 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"github.com/yanzay/log"
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	etcdDefaultPrefix  = "/gonsumer"
+	etcdStateKey       = "cluster"
+	etcdDialTimeout    = 5 * time.Second
+	etcdRequestTimeout = 10 * time.Second
+)
+
+// EtcdStorage keeps cluster state under a key of etcd v3. Writes are compare-and-set against the mod revision
+// read last, so a write racing with another scheduler instance fails with ErrStorageConflict. No leases are used,
+// state outlives the scheduler.
+type EtcdStorage struct {
+	endpoints []string
+	key       string
+	client    *clientv3.Client
+
+	lock sync.Mutex
+	// revision is the mod revision of the key read or written last, zero if the key does not exist
+	// and noVersion until the key is read.
+	revision int64
+}
+
+// NewEtcdStorage creates a storage from a connect string in form host:port[,host:port...][/prefix][?options].
+// Options cert, key and ca name PEM files of a TLS client certificate, its key and a trusted CA, e.g.
+// etcd1:2379,etcd2:2379/gonsumer?cert=client.pem&key=client-key.pem&ca=ca.pem
+func NewEtcdStorage(etcd string) (*EtcdStorage, error) {
+	endpoints, prefix, tlsInfo, err := parseEtcdConnect(etcd)
+	if err != nil {
+		return nil, err
+	}
+
+	config := clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: etcdDialTimeout,
+	}
+
+	if tlsInfo != nil {
+		config.TLS, err = tlsInfo.ClientConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := clientv3.New(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EtcdStorage{
+		endpoints: endpoints,
+		key:       prefix + "/" + etcdStateKey,
+		client:    client,
+		revision:  noVersion,
+	}, nil
+}
+
+func (es *EtcdStorage) Save(contents []byte) error {
+	es.lock.Lock()
+	defer es.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), etcdRequestTimeout)
+	defer cancel()
+
+	if es.revision == noVersion {
+		// nothing was read yet, so there is nothing to compare with
+		_, err := es.get(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	response, err := es.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(es.key), "=", es.revision)).
+		Then(clientv3.OpPut(es.key, string(contents))).
+		Commit()
+	if err != nil {
+		return err
+	}
+
+	if !response.Succeeded {
+		return ErrStorageConflict
+	}
+
+	es.revision = response.Header.Revision
+	return nil
+}
+
+func (es *EtcdStorage) Load() ([]byte, error) {
+	es.lock.Lock()
+	defer es.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), etcdRequestTimeout)
+	defer cancel()
+
+	contents, err := es.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(contents) == 0 {
+		return nil, ErrStorageUninitialized
+	}
+
+	return contents, nil
+}
+
+// Watch sends current contents of the key and then its contents after every change until stop is closed.
+// Watching does not affect the revision compared by Save.
+func (es *EtcdStorage) Watch(stop <-chan struct{}) (<-chan []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdRequestTimeout)
+	response, err := es.client.Get(ctx, es.key)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	watchCtx, watchCancel := context.WithCancel(context.Background())
+	watch := es.client.Watch(watchCtx, es.key, clientv3.WithRev(response.Header.Revision+1))
+
+	changes := make(chan []byte)
+	go func() {
+		defer close(changes)
+		defer watchCancel()
+
+		var contents []byte
+		if len(response.Kvs) > 0 {
+			contents = response.Kvs[0].Value
+		}
+
+		select {
+		case changes <- contents:
+		case <-stop:
+			return
+		}
+
+		for {
+			select {
+			case watchResponse, ok := <-watch:
+				if !ok {
+					return
+				}
+
+				if err := watchResponse.Err(); err != nil {
+					log.Warningf("Failed to watch %s: %s", es, err)
+					continue
+				}
+
+				for _, event := range watchResponse.Events {
+					select {
+					case changes <- event.Kv.Value:
+					case <-stop:
+						return
+					}
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+// Close closes connections to etcd.
+func (es *EtcdStorage) Close() error {
+	return es.client.Close()
+}
+
+func (es *EtcdStorage) String() string {
+	return fmt.Sprintf("%s%s", strings.Join(es.endpoints, ","), es.key)
+}
+
+// get reads the key remembering its mod revision. Must be called with lock held.
+func (es *EtcdStorage) get(ctx context.Context) ([]byte, error) {
+	response, err := es.client.Get(ctx, es.key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Kvs) == 0 {
+		es.revision = 0
+		return nil, nil
+	}
+
+	es.revision = response.Kvs[0].ModRevision
+	return response.Kvs[0].Value, nil
+}
+
+// parseEtcdConnect splits an etcd connect string to endpoints, key prefix and TLS settings, nil if TLS is not used.
+func parseEtcdConnect(etcd string) ([]string, string, *transport.TLSInfo, error) {
+	rawOptions := ""
+	if optionsIdx := strings.Index(etcd, "?"); optionsIdx != -1 {
+		etcd, rawOptions = etcd[:optionsIdx], etcd[optionsIdx+1:]
+	}
+
+	prefix := etcdDefaultPrefix
+	if prefixIdx := strings.Index(etcd, "/"); prefixIdx != -1 {
+		etcd, prefix = etcd[:prefixIdx], strings.TrimSuffix(etcd[prefixIdx:], "/")
+	}
+
+	if etcd == "" {
+		return nil, "", nil, ErrNoEtcdEndpoints
+	}
+
+	options, err := url.ParseQuery(rawOptions)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	tlsInfo := &transport.TLSInfo{}
+	for name := range options {
+		switch name {
+		case "cert":
+			tlsInfo.CertFile = options.Get(name)
+		case "key":
+			tlsInfo.KeyFile = options.Get(name)
+		case "ca":
+			tlsInfo.TrustedCAFile = options.Get(name)
+		default:
+			return nil, "", nil, fmt.Errorf("Unknown etcd option %s", name)
+		}
+	}
+
+	if (tlsInfo.CertFile == "") != (tlsInfo.KeyFile == "") {
+		return nil, "", nil, ErrEtcdCertWithoutKey
+	}
+
+	endpoints := strings.Split(etcd, ",")
+	if tlsInfo.Empty() && tlsInfo.TrustedCAFile == "" {
+		return endpoints, prefix, nil, nil
+	}
+
+	for i, endpoint := range endpoints {
+		endpoints[i] = "https://" + endpoint
+	}
+
+	return endpoints, prefix, tlsInfo, nil
+}
@@ -0,0 +1,142 @@
+package framework
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/server/v3/embed"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+// startEmbeddedEtcd runs a single member etcd server in-process and returns its client address.
+func startEmbeddedEtcd(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "gonsumer-etcd")
+	require.Nil(t, err)
+
+	config := embed.NewConfig()
+	config.Dir = dir
+	config.LogLevel = "error"
+
+	clientURL := url.URL{Scheme: "http", Host: freeAddress(t)}
+	peerURL := url.URL{Scheme: "http", Host: freeAddress(t)}
+	config.ListenClientUrls, config.AdvertiseClientUrls = []url.URL{clientURL}, []url.URL{clientURL}
+	config.ListenPeerUrls, config.AdvertisePeerUrls = []url.URL{peerURL}, []url.URL{peerURL}
+	config.InitialCluster = config.InitialClusterFromName(config.Name)
+
+	etcd, err := embed.StartEtcd(config)
+	require.Nil(t, err)
+
+	select {
+	case <-etcd.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		etcd.Close()
+		t.Fatal("Timed out waiting for embedded etcd to start")
+	}
+
+	return clientURL.Host, func() {
+		etcd.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func freeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer listener.Close()
+
+	return listener.Addr().String()
+}
+
+func TestEtcdStorage(t *testing.T) {
+	address, stopEtcd := startEmbeddedEtcd(t)
+	defer stopEtcd()
+
+	contents := "hello world"
+	storage, err := NewStorage(fmt.Sprintf("etcd:%s/test", address))
+	require.Nil(t, err)
+	etcdStorage := storage.(*EtcdStorage)
+	defer etcdStorage.Close()
+	assert.Equal(t, address+"/test/cluster", etcdStorage.String())
+
+	_, err = storage.Load()
+	assert.Equal(t, ErrStorageUninitialized, err)
+
+	require.Nil(t, storage.Save([]byte(contents)))
+	loadedContents, err := storage.Load()
+	require.Nil(t, err)
+	assert.Equal(t, contents, string(loadedContents))
+
+	stop := make(chan struct{})
+	defer close(stop)
+	changes, err := etcdStorage.Watch(stop)
+	require.Nil(t, err)
+	assert.Equal(t, contents, string(awaitChange(t, changes)))
+
+	// a write made by another instance in the meantime is not overwritten
+	other, err := NewEtcdStorage(address + "/test")
+	require.Nil(t, err)
+	defer other.Close()
+	_, err = other.Load()
+	require.Nil(t, err)
+	require.Nil(t, other.Save([]byte("other")))
+	assert.Equal(t, "other", string(awaitChange(t, changes)))
+	assert.Equal(t, ErrStorageConflict, storage.Save([]byte("stale")))
+
+	// writing is possible again once the new revision is read
+	loadedContents, err = storage.Load()
+	require.Nil(t, err)
+	assert.Equal(t, "other", string(loadedContents))
+	require.Nil(t, storage.Save([]byte(contents)))
+	assert.Equal(t, contents, string(awaitChange(t, changes)))
+
+	// keys under another prefix are independent
+	unrelated, err := NewEtcdStorage(address + "/unrelated")
+	require.Nil(t, err)
+	defer unrelated.Close()
+	_, err = unrelated.Load()
+	assert.Equal(t, ErrStorageUninitialized, err)
+	require.Nil(t, unrelated.Save([]byte("unrelated")))
+	loadedContents, err = storage.Load()
+	require.Nil(t, err)
+	assert.Equal(t, contents, string(loadedContents))
+}
+
+func TestParseEtcdConnect(t *testing.T) {
+	endpoints, prefix, tlsInfo, err := parseEtcdConnect("etcd1:2379,etcd2:2379")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"etcd1:2379", "etcd2:2379"}, endpoints)
+	assert.Equal(t, etcdDefaultPrefix, prefix)
+	assert.Nil(t, tlsInfo)
+
+	endpoints, prefix, tlsInfo, err = parseEtcdConnect("etcd1:2379/mesos/gonsumer/?cert=client.pem&key=client-key.pem&ca=ca.pem")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"https://etcd1:2379"}, endpoints)
+	assert.Equal(t, "/mesos/gonsumer", prefix)
+	require.NotNil(t, tlsInfo)
+	assert.Equal(t, "client.pem", tlsInfo.CertFile)
+	assert.Equal(t, "client-key.pem", tlsInfo.KeyFile)
+	assert.Equal(t, "ca.pem", tlsInfo.TrustedCAFile)
+
+	// server certificate may be verified without a client certificate
+	endpoints, _, tlsInfo, err = parseEtcdConnect("etcd1:2379?ca=ca.pem")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"https://etcd1:2379"}, endpoints)
+	require.NotNil(t, tlsInfo)
+
+	_, _, _, err = parseEtcdConnect("/gonsumer")
+	assert.Equal(t, ErrNoEtcdEndpoints, err)
+
+	_, _, _, err = parseEtcdConnect("etcd1:2379?cert=client.pem")
+	assert.Equal(t, ErrEtcdCertWithoutKey, err)
+
+	_, _, _, err = parseEtcdConnect("etcd1:2379?user=root")
+	assert.NotNil(t, err)
+
+	_, err = NewEtcdStorage("etcd1:2379?cert=missing.pem&key=missing-key.pem")
+	assert.NotNil(t, err)
+}
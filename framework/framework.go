@@ -2,11 +2,13 @@ package framework
 
 import (
 	"github.com/golang/protobuf/proto"
+	_ "github.com/mesos/mesos-go/detector/zoo"
 	"github.com/mesos/mesos-go/mesosproto"
 	util "github.com/mesos/mesos-go/mesosutil"
 	mesos "github.com/mesos/mesos-go/scheduler"
 	"github.com/yanzay/log"
 	"net"
+	"os"
 	"strings"
 	"time"
 )
@@ -20,55 +22,205 @@ type GonsumerFrameworkConfig struct {
 	FrameworkTimeout time.Duration
 	User             string
 	BindIP           string
+
+	// SchedulerAPI selects how the framework talks to Mesos master, either SchedulerAPIDriver or SchedulerAPIHTTP.
+	SchedulerAPI string
+
+	// Principal authenticates the framework with Mesos master using a secret read from SecretFile.
+	Principal  string
+	SecretFile string
+
+	// Resources requested by each consumer task unless specified explicitly for a group.
+	ConsumerCpus float64
+	ConsumerMem  float64
+	ConsumerDisk float64
+	// UnreachableTimeout and KillGracePeriod are used for groups that do not specify their own.
+	UnreachableTimeout time.Duration
+	KillGracePeriod    time.Duration
+
+	// LeaderElection enables ZooKeeper leader election between several scheduler instances.
+	LeaderElection bool
+	// LeaderZk is the ZooKeeper host:port/path to elect the leader at. Defaults to zk: storage connect string.
+	LeaderZk string
+
+	// RefuseSeconds is how long declined offers are filtered out for.
+	RefuseSeconds float64
+
+	// RestartBackoff controls how failed consumers are relaunched.
+	RestartBackoff RestartBackoff
+
+	// ExecutorBinary is the gonsumer-mesos binary served to executors. Defaults to the running binary.
+	ExecutorBinary string
+	// Artifacts are extra files served to executors along with ExecutorBinary.
+	Artifacts []string
 }
 
 func NewConfig() GonsumerFrameworkConfig {
 	return GonsumerFrameworkConfig{
-		FrameworkName:    "gonsumer",
-		FrameworkRole:    "*",
-		FrameworkStorage: "file:/tmp/gonsumer.json",
-		FrameworkTimeout: 365 * 24 * time.Hour,
-		Master:           "127.0.0.1:5050",
+		FrameworkName:      "gonsumer",
+		FrameworkRole:      "*",
+		FrameworkStorage:   "file:/tmp/gonsumer.json",
+		FrameworkTimeout:   365 * 24 * time.Hour,
+		Master:             "127.0.0.1:5050",
+		SchedulerAPI:       DefaultSchedulerAPI,
+		ConsumerCpus:       DefaultConsumerCpus,
+		ConsumerMem:        DefaultConsumerMem,
+		ConsumerDisk:       DefaultConsumerDisk,
+		UnreachableTimeout: DefaultUnreachableTimeout,
+		KillGracePeriod:    DefaultKillGracePeriod,
+		RefuseSeconds:      DefaultRefuseSeconds,
+		RestartBackoff: RestartBackoff{
+			Delay:      DefaultRestartBackoff,
+			MaxDelay:   DefaultRestartBackoffMax,
+			ResetAfter: DefaultRestartResetAfter,
+		},
 	}
 }
 
 type Framework struct {
 	config    GonsumerFrameworkConfig
 	driver    mesos.SchedulerDriver
-	scheduler Scheduler
+	scheduler *GonsumerScheduler
 	server    Server
+	election  LeaderElection
 }
 
-func New(config GonsumerFrameworkConfig) (*Framework, error) {
-	storage, err := NewStorage(config.FrameworkStorage)
+// Option customizes a framework created by New.
+type Option func(*options)
+
+type options struct {
+	storage Storage
+}
+
+// WithStorage makes the framework keep cluster state in a given storage instead of creating one from
+// FrameworkStorage of the config.
+func WithStorage(storage Storage) Option {
+	return func(o *options) {
+		o.storage = storage
+	}
+}
+
+func New(config GonsumerFrameworkConfig, opts ...Option) (*Framework, error) {
+	var frameworkOptions options
+	for _, opt := range opts {
+		opt(&frameworkOptions)
+	}
+
+	err := ValidateMaster(config.Master)
 	if err != nil {
 		return nil, err
 	}
 
-	scheduler, err := NewScheduler(storage)
+	switch config.SchedulerAPI {
+	case "", SchedulerAPIDriver:
+	case SchedulerAPIHTTP:
+		if strings.HasPrefix(config.Master, zkMasterPrefix) {
+			return nil, ErrHTTPMasterZk
+		}
+	default:
+		return nil, ErrUnknownSchedulerAPI
+	}
+
+	if config.ExecutorBinary == "" {
+		executorBinary, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		config.ExecutorBinary = executorBinary
+	}
+
+	// fail fast on misconfigured credentials rather than once elected
+	_, err = newCredential(config)
 	if err != nil {
 		return nil, err
 	}
 
-	driver, err := newSchedulerDriver(scheduler, config)
+	storage := frameworkOptions.storage
+	if storage == nil {
+		storage, err = NewStorage(config.FrameworkStorage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scheduler, err := NewScheduler(config, storage)
 	if err != nil {
 		return nil, err
 	}
 
 	server := NewHttpServer(listenAddr(config.Api), scheduler)
 
+	var election LeaderElection
+	if config.LeaderElection {
+		leaderZk := config.LeaderZk
+		if leaderZk == "" {
+			zkStorage, ok := storage.(*ZKStorage)
+			if !ok {
+				return nil, ErrLeaderElectionZk
+			}
+			leaderZk = zkStorage.String()
+		}
+
+		election = NewZKLeaderElection(leaderZk, config.Api)
+		server.SetLeaderElection(election)
+	}
+
 	return &Framework{
 		config:    config,
-		driver:    driver,
 		scheduler: scheduler,
 		server:    server,
+		election:  election,
 	}, nil
 }
 
 func (f *Framework) Start() error {
 	go f.server.Start()
 
-	if status, err := f.driver.Run(); err != nil {
+	if f.election != nil {
+		log.Info("Waiting to become the leader")
+		err := f.election.Campaign()
+		if err != nil {
+			return err
+		}
+		defer f.election.Resign()
+		log.Info("Elected as the leader")
+
+		// cluster state might have been changed by the previous leader
+		err = f.scheduler.LoadClusterState()
+		if err != nil {
+			return err
+		}
+	}
+
+	driver, err := newSchedulerDriver(f.scheduler, f.config)
+	if err != nil {
+		return err
+	}
+	f.driver = driver
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go f.abortWhenReplaced(driver, stop)
+
+	status, err := driver.Run()
+	if f.election != nil {
+		select {
+		case <-f.election.Lost():
+			return ErrLeadershipLost
+		default:
+		}
+	}
+
+	select {
+	case <-f.scheduler.Deposed():
+		return ErrStorageConflict
+	default:
+	}
+
+	if err != nil {
+		if isAuthenticationError(err.Error()) {
+			log.Errorf("Failed to authenticate with Mesos master as principal %s: %s", f.config.Principal, err)
+		}
 		log.Infof("Framework stopped with status %s and error: %s\n", status.String(), err)
 		return err
 	}
@@ -76,6 +228,26 @@ func (f *Framework) Start() error {
 	return nil
 }
 
+// abortWhenReplaced aborts a given driver once another scheduler instance takes over, either by winning the leader
+// election or by modifying stored cluster state, until stop is closed.
+func (f *Framework) abortWhenReplaced(driver mesos.SchedulerDriver, stop <-chan struct{}) {
+	var lost <-chan struct{}
+	if f.election != nil {
+		lost = f.election.Lost()
+	}
+
+	select {
+	case <-lost:
+		log.Error("Leadership lost, aborting scheduler driver")
+	case <-f.scheduler.Deposed():
+		log.Error("Cluster state modified by another scheduler instance, aborting scheduler driver")
+	case <-stop:
+		return
+	}
+
+	driver.Abort()
+}
+
 func newSchedulerDriver(gonsumerScheduler *GonsumerScheduler, config GonsumerFrameworkConfig) (mesos.SchedulerDriver, error) {
 	frameworkInfo := &mesosproto.FrameworkInfo{
 		User:            proto.String(config.User),
@@ -83,6 +255,12 @@ func newSchedulerDriver(gonsumerScheduler *GonsumerScheduler, config GonsumerFra
 		Role:            proto.String(config.FrameworkRole),
 		FailoverTimeout: proto.Float64(float64(config.FrameworkTimeout / 1e9)),
 		Checkpoint:      proto.Bool(true),
+		Capabilities: []*mesosproto.FrameworkInfo_Capability{
+			// keeps consumers running through network partitions instead of having them killed by the master
+			{Type: mesosproto.FrameworkInfo_Capability_PARTITION_AWARE.Enum()},
+			// lets the executor report it is committing offsets before the task is killed
+			{Type: mesosproto.FrameworkInfo_Capability_TASK_KILLING_STATE.Enum()},
+		},
 	}
 
 	frameworkID := gonsumerScheduler.Cluster().GetFrameworkID()
@@ -90,16 +268,34 @@ func newSchedulerDriver(gonsumerScheduler *GonsumerScheduler, config GonsumerFra
 		frameworkInfo.Id = util.NewFrameworkID(frameworkID)
 	}
 
+	credential, err := newCredential(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if credential != nil {
+		frameworkInfo.Principal = credential.Principal
+	}
+
+	if config.SchedulerAPI == SchedulerAPIHTTP {
+		return NewHTTPSchedulerDriver(gonsumerScheduler, frameworkInfo, config.Master, credential)
+	}
+
 	driverConfig := mesos.DriverConfig{
-		Scheduler: gonsumerScheduler,
-		Framework: frameworkInfo,
-		Master:    config.Master,
+		Scheduler:  gonsumerScheduler,
+		Framework:  frameworkInfo,
+		Master:     config.Master,
+		Credential: credential,
 	}
 
 	if config.BindIP != "" {
 		driverConfig.BindingAddress = net.ParseIP(config.BindIP)
 	}
 
+	if credential != nil {
+		driverConfig.WithAuthContext = authContext(driverConfig.BindingAddress)
+	}
+
 	driver, err := mesos.NewMesosSchedulerDriver(driverConfig)
 	if err != nil {
 		return nil, err
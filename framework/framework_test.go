@@ -0,0 +1,60 @@
+package framework
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNewWithStorage(t *testing.T) {
+	config := NewConfig()
+	config.FrameworkStorage = "unknown:storage"
+	_, err := New(config)
+	assert.Equal(t, ErrUnsupportedStorage, err)
+
+	storage := NewMockStorage()
+	framework, err := New(config, WithStorage(storage))
+	require.Nil(t, err)
+	assert.Equal(t, storage, framework.scheduler.storage)
+
+	// leader election needs ZooKeeper, which a given storage other than ZKStorage does not provide
+	config.LeaderElection = true
+	_, err = New(config, WithStorage(storage))
+	assert.Equal(t, ErrLeaderElectionZk, err)
+
+	config.LeaderZk = "zookeeper:2181/gonsumer"
+	framework, err = New(config, WithStorage(storage))
+	require.Nil(t, err)
+	assert.NotNil(t, framework.election)
+}
+
+func TestAbortWhenReplaced(t *testing.T) {
+	storage := NewMockStorage()
+	framework, err := New(NewConfig(), WithStorage(storage))
+	require.Nil(t, err)
+	driver := NewMockSchedulerDriver()
+
+	stop := make(chan struct{})
+	go framework.abortWhenReplaced(driver, stop)
+
+	storage.SaveError = ErrStorageConflict
+	framework.scheduler.SaveClusterState()
+	select {
+	case <-driver.Aborts:
+	case <-time.After(time.Second):
+		t.Fatal("Driver should be aborted once another scheduler instance modifies cluster state")
+	}
+
+	// nothing is aborted once the driver has stopped
+	framework, err = New(NewConfig(), WithStorage(NewMockStorage()))
+	require.Nil(t, err)
+	done := make(chan struct{})
+	go func() {
+		framework.abortWhenReplaced(driver, stop)
+		close(done)
+	}()
+	close(stop)
+	<-done
+	assert.Empty(t, driver.Aborts)
+}
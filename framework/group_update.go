@@ -0,0 +1,99 @@
+package framework
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GroupUpdate describes a partial change of group configuration. Nil fields are left unchanged.
+type GroupUpdate struct {
+	Subscriptions    []string
+	BootstrapBrokers []string
+
+	Cpus *float64
+	Mem  *float64
+	Disk *float64
+
+	Options map[string]string
+
+	UnreachableTimeout *time.Duration
+	KillGracePeriod    *time.Duration
+}
+
+// Apply applies this update to a given group.
+func (u *GroupUpdate) Apply(group *Group) {
+	if u.Subscriptions != nil {
+		group.Subscriptions = u.Subscriptions
+	}
+
+	if u.BootstrapBrokers != nil {
+		group.BootstrapBrokers = u.BootstrapBrokers
+	}
+
+	if u.Cpus != nil {
+		group.Cpus = *u.Cpus
+	}
+
+	if u.Mem != nil {
+		group.Mem = *u.Mem
+	}
+
+	if u.Disk != nil {
+		group.Disk = *u.Disk
+	}
+
+	if u.Options != nil {
+		group.Options = u.Options
+	}
+
+	if u.UnreachableTimeout != nil {
+		group.UnreachableTimeout = *u.UnreachableTimeout
+	}
+
+	if u.KillGracePeriod != nil {
+		group.KillGracePeriod = *u.KillGracePeriod
+	}
+}
+
+// restartsConsumers returns true if this update changes configuration of consumer tasks, so they have to be restarted.
+func (u *GroupUpdate) restartsConsumers() bool {
+	return u.Subscriptions != nil || u.BootstrapBrokers != nil || u.Cpus != nil || u.Mem != nil || u.Disk != nil ||
+		u.Options != nil
+}
+
+// ParseOptions parses consumer options in form k1=v1,k2=v2.
+func ParseOptions(rawOptions string) (map[string]string, error) {
+	options := make(map[string]string)
+	if rawOptions == "" {
+		return options, nil
+	}
+
+	for _, option := range strings.Split(rawOptions, ",") {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("Invalid consumer option %s, expected key=value", option)
+		}
+
+		options[kv[0]] = kv[1]
+	}
+
+	return options, nil
+}
+
+// FormatOptions formats consumer options to a form accepted by ParseOptions.
+func FormatOptions(options map[string]string) string {
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]string, 0, len(options))
+	for _, key := range keys {
+		kvs = append(kvs, fmt.Sprintf("%s=%s", key, options[key]))
+	}
+
+	return strings.Join(kvs, ",")
+}
@@ -0,0 +1,43 @@
+package framework
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGroupUpdateApply(t *testing.T) {
+	group := newTestGroup("foo")
+	group.Subscriptions = []string{"foo"}
+	group.BootstrapBrokers = []string{"localhost:9092"}
+
+	cpus := 2.0
+	update := &GroupUpdate{
+		Subscriptions: []string{"bar", "baz"},
+		Cpus:          &cpus,
+	}
+	update.Apply(group)
+
+	assert.Equal(t, []string{"bar", "baz"}, group.Subscriptions)
+	assert.Equal(t, []string{"localhost:9092"}, group.BootstrapBrokers)
+	assert.Equal(t, 2.0, group.Cpus)
+	assert.Equal(t, float64(DefaultConsumerMem), group.Mem)
+	assert.Nil(t, group.Options)
+}
+
+func TestParseOptions(t *testing.T) {
+	options, err := ParseOptions("")
+	require.Nil(t, err)
+	assert.Empty(t, options)
+
+	options, err = ParseOptions("fetch.size=1024,client.id=gonsumer,empty=")
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"fetch.size": "1024", "client.id": "gonsumer", "empty": ""}, options)
+	assert.Equal(t, "client.id=gonsumer,empty=,fetch.size=1024", FormatOptions(options))
+
+	_, err = ParseOptions("fetch.size")
+	assert.NotNil(t, err)
+
+	_, err = ParseOptions("=1024")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,299 @@
+package framework
+
+import (
+	"fmt"
+	util "github.com/mesos/mesos-go/mesosutil"
+	"github.com/yanzay/log"
+	"time"
+)
+
+// AddGroup adds a new group and asks for offers to launch its consumers.
+func (s *GonsumerScheduler) AddGroup(group *Group) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.cluster.ExistsGroup(group.ID) {
+		return ErrGroupExists
+	}
+
+	log.Infof("Adding group %s", group.ID)
+	s.cluster.AddGroup(group)
+	if len(group.PendingConsumers()) > 0 {
+		s.reviveOffers(s.driver, fmt.Sprintf("group %s added", group.ID))
+	}
+
+	return s.SaveClusterState()
+}
+
+// StartGroup makes a stopped group schedulable again. Starting a group that is not stopped is a no-op.
+func (s *GonsumerScheduler) StartGroup(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	group := s.cluster.GetGroup(id)
+	if group == nil {
+		return ErrGroupNotFound
+	}
+
+	log.Infof("Starting group %s", id)
+	group.Stopped = false
+	stopped := group.ConsumersWithState(ConsumerStateStopped)
+	for _, consumer := range stopped {
+		err := consumer.Transition(ConsumerStatePending)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(stopped) > 0 {
+		s.reviveOffers(s.driver, fmt.Sprintf("group %s started", id))
+	}
+
+	return s.SaveClusterState()
+}
+
+// StopGroup kills all tasks of a given group and prevents it from being scheduled until started again.
+// Consumers stay stopping until their tasks commit offsets and terminate.
+func (s *GonsumerScheduler) StopGroup(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	group := s.cluster.GetGroup(id)
+	if group == nil {
+		return ErrGroupNotFound
+	}
+
+	s.stopGroup(group)
+	return s.SaveClusterState()
+}
+
+// UpdateGroup applies a given configuration change to a group and restarts its running consumers one at a time.
+func (s *GonsumerScheduler) UpdateGroup(id string, update *GroupUpdate) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	group := s.cluster.GetGroup(id)
+	if group == nil {
+		return ErrGroupNotFound
+	}
+
+	log.Infof("Updating group %s", id)
+	update.Apply(group)
+	if !update.restartsConsumers() {
+		return s.SaveClusterState()
+	}
+
+	for _, consumer := range group.Consumers {
+		switch consumer.State {
+		case ConsumerStateStaging, ConsumerStateRunning:
+			consumer.Restart = true
+		}
+	}
+
+	s.rollingRestart(group)
+	return s.SaveClusterState()
+}
+
+// ScaleGroup changes the number of consumers in a given group. Tasks of removed consumers are killed.
+func (s *GonsumerScheduler) ScaleGroup(id string, instances int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	group := s.cluster.GetGroup(id)
+	if group == nil {
+		return ErrGroupNotFound
+	}
+
+	if instances < 0 {
+		return ErrInvalidInstances
+	}
+
+	log.Infof("Scaling group %s to %d instance(s)", id, instances)
+	scaleUp := instances > len(group.Consumers)
+	for _, consumer := range group.Scale(instances) {
+		s.stopConsumer(group, consumer)
+		if consumer.State == ConsumerStateStopping {
+			group.Stopping = append(group.Stopping, consumer)
+		}
+	}
+
+	if scaleUp && !group.Stopped {
+		s.reviveOffers(s.driver, fmt.Sprintf("group %s scaled up", id))
+	}
+
+	// a removed consumer might have been restarting
+	s.rollingRestart(group)
+	return s.SaveClusterState()
+}
+
+// rollingRestart kills the next consumer awaiting a restart unless there is a restarted consumer
+// whose replacement is not running yet.
+func (s *GonsumerScheduler) rollingRestart(group *Group) {
+	for _, consumer := range group.Consumers {
+		if consumer.Restarting {
+			return
+		}
+	}
+
+	for _, consumer := range group.Consumers {
+		if !consumer.Restart {
+			continue
+		}
+
+		consumer.Restart = false
+		if consumer.State != ConsumerStateRunning {
+			// a consumer that is not running will pick up the new configuration once it is launched
+			continue
+		}
+
+		log.Infof("Restarting consumer %s of group %s", consumer.ID, group.ID)
+		consumer.Restarting = true
+		s.killConsumer(group, consumer)
+		return
+	}
+}
+
+// RemoveGroup removes a stopped group from the cluster. If force is set, the group is stopped first.
+func (s *GonsumerScheduler) RemoveGroup(id string, force bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	group := s.cluster.GetGroup(id)
+	if group == nil {
+		return ErrGroupNotFound
+	}
+
+	if !group.Stopped {
+		if !force {
+			return ErrGroupNotStopped
+		}
+
+		s.stopGroup(group)
+	}
+
+	log.Infof("Removing group %s", id)
+	s.cluster.RemoveGroup(id)
+	return s.SaveClusterState()
+}
+
+func (s *GonsumerScheduler) stopGroup(group *Group) {
+	log.Infof("Stopping group %s", group.ID)
+	group.Stopped = true
+
+	for _, consumer := range group.Consumers {
+		s.stopConsumer(group, consumer)
+	}
+}
+
+// stopConsumer kills the task of a given consumer if it may still be alive. The consumer stays stopping until
+// the task terminates, see Consumer.Update.
+func (s *GonsumerScheduler) stopConsumer(group *Group, consumer *Consumer) {
+	state := ConsumerStateStopped
+	switch consumer.State {
+	case ConsumerStateStopping:
+		// already being killed
+		return
+	case ConsumerStateStaging, ConsumerStateRunning, ConsumerStateUnreachable:
+		state = ConsumerStateStopping
+		if consumer.KillTime.IsZero() {
+			s.killConsumer(group, consumer)
+		}
+	case ConsumerStateLost:
+		// the task is gone already, make sure it does not come back
+		if consumer.TaskID != "" {
+			s.killTask(consumer.TaskID)
+		}
+		consumer.Reset()
+	default:
+		consumer.Reset()
+	}
+
+	consumer.Restart = false
+	consumer.Restarting = false
+	consumer.ResetFailures()
+	err := consumer.Transition(state)
+	if err != nil {
+		log.Errorf("Failed to stop consumer %s: %s", consumer.ID, err)
+	}
+}
+
+// consumerStopped is called once the task of a stopping consumer has terminated. Removed consumers are forgotten,
+// consumers of a group that has been started again in the meantime are relaunched.
+func (s *GonsumerScheduler) consumerStopped(group *Group, consumer *Consumer) {
+	log.Infof("Consumer %s of group %s stopped", consumer.ID, group.ID)
+	if group.removeStopping(consumer) || group.Stopped {
+		// the group itself may have been removed while its consumers were stopping
+		s.cluster.RemoveStoppedGroups()
+		return
+	}
+
+	err := consumer.Transition(ConsumerStatePending)
+	if err != nil {
+		log.Errorf("Failed to start consumer %s of group %s: %s", consumer.ID, group.ID, err)
+		return
+	}
+
+	s.reviveOffers(s.driver, fmt.Sprintf("consumer %s of group %s is pending", consumer.ID, group.ID))
+}
+
+// killConsumer kills the task of a given consumer giving it the group grace period to commit offsets and leave
+// the consumer group. The kill is escalated if the task is still alive once the grace period expires.
+func (s *GonsumerScheduler) killConsumer(group *Group, consumer *Consumer) {
+	consumer.KillTime = time.Now()
+	s.killTask(consumer.TaskID)
+	s.scheduleKillEscalation(consumer.TaskID, group.KillGracePeriod)
+}
+
+func (s *GonsumerScheduler) scheduleKillEscalation(taskID string, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		s.escalateKill(taskID)
+	})
+}
+
+// escalateKill kills a given task again unless it has terminated already. The executor gives up committing
+// offsets and exits once it receives the second kill.
+func (s *GonsumerScheduler) escalateKill(taskID string) {
+	group, consumer := s.cluster.GetConsumerByTaskID(taskID)
+	if consumer == nil || consumer.KillTime.IsZero() {
+		return
+	}
+
+	log.Warningf("Consumer %s of group %s did not stop within %s, killing task %s forcefully", consumer.ID, group.ID,
+		group.KillGracePeriod, taskID)
+	consumer.AddEvent("Task %s did not stop within %s, killed forcefully", taskID, group.KillGracePeriod)
+	s.killTask(taskID)
+	s.SaveClusterState()
+}
+
+// scheduleKillEscalations rearms escalation of kills issued before a failover.
+func (s *GonsumerScheduler) scheduleKillEscalations(now time.Time) {
+	for _, group := range s.allGroups() {
+		for _, consumer := range group.allConsumers() {
+			if consumer.TaskID == "" || consumer.KillTime.IsZero() {
+				continue
+			}
+
+			delay := consumer.KillTime.Add(group.KillGracePeriod).Sub(now)
+			if delay < 0 {
+				delay = 0
+			}
+			s.scheduleKillEscalation(consumer.TaskID, delay)
+		}
+	}
+}
+
+func (s *GonsumerScheduler) killTask(taskID string) {
+	if s.driver == nil {
+		log.Warningf("Scheduler driver is not registered, cannot kill task %s", taskID)
+		return
+	}
+
+	log.Infof("Killing task %s", taskID)
+	_, err := s.driver.KillTask(util.NewTaskID(taskID))
+	if err != nil {
+		log.Errorf("Failed to kill task %s: %s", taskID, err)
+	}
+}
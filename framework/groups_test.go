@@ -0,0 +1,345 @@
+package framework
+
+import (
+	"encoding/json"
+	"fmt"
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	util "github.com/mesos/mesos-go/mesosutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStopStartGroup(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+	scheduler.Registered(driver, util.NewFrameworkID("framework"), util.NewMasterInfo("master", 0, 5050))
+
+	group := newTestGroup("foo")
+	group.Consumers = append(group.Consumers, NewConsumer("1"))
+	scheduler.Cluster().AddGroup(group)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", DefaultConsumerCpus, DefaultConsumerMem)})
+	running := group.Consumers[0]
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(running.TaskID), mesos.TaskState_TASK_RUNNING))
+
+	assert.Equal(t, ErrGroupNotFound, scheduler.StopGroup("bar"))
+
+	err := scheduler.StopGroup("foo")
+	require.Nil(t, err)
+	assert.True(t, group.Stopped)
+	assert.Equal(t, 1, driver.KillTaskCount)
+	assert.Equal(t, ConsumerStateStopping, running.State)
+	assert.Len(t, group.ConsumersWithState(ConsumerStateStopped), 1)
+	assert.NotEmpty(t, running.TaskID) // until the task is actually killed
+	assert.False(t, running.KillTime.IsZero())
+
+	// stopping again should not kill the task twice
+	require.Nil(t, scheduler.StopGroup("foo"))
+	assert.Equal(t, 1, driver.KillTaskCount)
+
+	// the task reports it is committing offsets before it is gone
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(running.TaskID), mesos.TaskState_TASK_KILLING))
+	assert.Equal(t, ConsumerStateStopping, running.State)
+
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(running.TaskID), mesos.TaskState_TASK_KILLED))
+	assert.Equal(t, ConsumerStateStopped, running.State)
+	assert.Empty(t, running.TaskID)
+	assert.True(t, running.KillTime.IsZero())
+
+	// stopped groups should not be scheduled
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("2", 4, 4096)})
+	assert.Equal(t, 1, driver.LaunchTasksCount)
+
+	assert.Equal(t, ErrGroupNotFound, scheduler.StartGroup("bar"))
+
+	err = scheduler.StartGroup("foo")
+	require.Nil(t, err)
+	assert.False(t, group.Stopped)
+	assert.Len(t, group.PendingConsumers(), 2)
+
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("3", 4, 4096)})
+	assert.Equal(t, 2, driver.LaunchTasksCount)
+	assert.Empty(t, group.PendingConsumers())
+}
+
+func TestRemoveGroup(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+	scheduler.Registered(driver, util.NewFrameworkID("framework"), util.NewMasterInfo("master", 0, 5050))
+
+	scheduler.Cluster().AddGroup(newTestGroup("foo"))
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+
+	assert.Equal(t, ErrGroupNotFound, scheduler.RemoveGroup("bar", false))
+	assert.Equal(t, ErrGroupNotStopped, scheduler.RemoveGroup("foo", false))
+	assert.True(t, scheduler.Cluster().ExistsGroup("foo"))
+
+	require.Nil(t, scheduler.StopGroup("foo"))
+	require.Nil(t, scheduler.RemoveGroup("foo", false))
+	assert.False(t, scheduler.Cluster().ExistsGroup("foo"))
+
+	// forced removal should stop the group first
+	scheduler.Cluster().AddGroup(newTestGroup("bar"))
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("2", 4, 4096)})
+	kills := driver.KillTaskCount
+
+	require.Nil(t, scheduler.RemoveGroup("bar", true))
+	assert.False(t, scheduler.Cluster().ExistsGroup("bar"))
+	assert.Equal(t, kills+1, driver.KillTaskCount)
+}
+
+func TestUpdateGroupRollingRestart(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+	scheduler.Registered(driver, util.NewFrameworkID("framework"), util.NewMasterInfo("master", 0, 5050))
+
+	group := newTestGroup("foo")
+	group.Consumers = append(group.Consumers, NewConsumer("1"))
+	scheduler.Cluster().AddGroup(group)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	for _, consumer := range group.Consumers {
+		scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(consumer.TaskID), mesos.TaskState_TASK_RUNNING))
+	}
+
+	assert.Equal(t, ErrGroupNotFound, scheduler.UpdateGroup("bar", new(GroupUpdate)))
+
+	mem := 512.0
+	err := scheduler.UpdateGroup("foo", &GroupUpdate{Mem: &mem, Options: map[string]string{"fetch.size": "1024"}})
+	require.Nil(t, err)
+	assert.Equal(t, 512.0, group.Mem)
+	assert.Equal(t, DefaultConsumerCpus, group.Cpus)
+	assert.Equal(t, "1024", group.Options["fetch.size"])
+
+	// only the first consumer should be restarted
+	first, second := group.Consumers[0], group.Consumers[1]
+	assert.Equal(t, 1, driver.KillTaskCount)
+	assert.True(t, first.Restarting)
+	assert.True(t, second.Restart)
+	assert.False(t, second.Restarting)
+
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(first.TaskID), mesos.TaskState_TASK_KILLED))
+	assert.Equal(t, ConsumerStatePending, first.State)
+	assert.Equal(t, 1, driver.KillTaskCount)
+
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("2", 4, 4096)})
+	require.Equal(t, ConsumerStateStaging, first.State)
+	assert.Equal(t, 512.0, driver.LaunchedTasks[len(driver.LaunchedTasks)-1].GetResources()[1].GetScalar().GetValue())
+	assert.Equal(t, 1, driver.KillTaskCount)
+
+	// the next consumer is restarted only after the replacement is running
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(first.TaskID), mesos.TaskState_TASK_RUNNING))
+	assert.False(t, first.Restarting)
+	assert.Equal(t, 2, driver.KillTaskCount)
+	assert.False(t, second.Restart)
+	assert.True(t, second.Restarting)
+}
+
+func TestUpdateGroupWithoutRestart(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+	scheduler.Registered(driver, util.NewFrameworkID("framework"), util.NewMasterInfo("master", 0, 5050))
+
+	group := newTestGroup("foo")
+	scheduler.Cluster().AddGroup(group)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(group.Consumers[0].TaskID), mesos.TaskState_TASK_RUNNING))
+
+	// unreachable policy is a scheduler setting, running consumers are not affected
+	unreachableTimeout := time.Minute
+	require.Nil(t, scheduler.UpdateGroup("foo", &GroupUpdate{UnreachableTimeout: &unreachableTimeout}))
+	assert.Equal(t, time.Minute, group.UnreachableTimeout)
+	assert.Equal(t, 0, driver.KillTaskCount)
+	assert.False(t, group.Consumers[0].Restart)
+}
+
+func TestScaleGroup(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+	scheduler.Registered(driver, util.NewFrameworkID("framework"), util.NewMasterInfo("master", 0, 5050))
+
+	group := newTestGroup("foo")
+	scheduler.Cluster().AddGroup(group)
+
+	assert.Equal(t, ErrGroupNotFound, scheduler.ScaleGroup("bar", 1))
+	assert.Equal(t, ErrInvalidInstances, scheduler.ScaleGroup("foo", -1))
+
+	require.Nil(t, scheduler.ScaleGroup("foo", 3))
+	assert.Equal(t, 3, group.Instances)
+	require.Len(t, group.Consumers, 3)
+	assert.Equal(t, "2", group.Consumers[2].ID)
+	assert.Len(t, group.PendingConsumers(), 3)
+
+	// launch consumers one by one so that launch times differ
+	for i := 0; i < 3; i++ {
+		scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer(fmt.Sprint(i), DefaultConsumerCpus, DefaultConsumerMem)})
+		group.Consumers[i].LaunchTime = time.Unix(int64(i), 0)
+	}
+	assert.Empty(t, group.PendingConsumers())
+
+	// the most recently launched consumers should be killed first
+	require.Nil(t, scheduler.ScaleGroup("foo", 1))
+	assert.Equal(t, 2, driver.KillTaskCount)
+	require.Len(t, group.Consumers, 1)
+	assert.Equal(t, "0", group.Consumers[0].ID)
+
+	// removed consumers are tracked until their tasks terminate
+	require.Len(t, group.Stopping, 2)
+	removed := group.Stopping[0]
+	assert.Equal(t, ConsumerStateStopping, removed.State)
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(removed.TaskID), mesos.TaskState_TASK_KILLED))
+	require.Len(t, group.Stopping, 1)
+	assert.NotEqual(t, removed, group.Stopping[0])
+	assert.Equal(t, ConsumerStateStopped, removed.State)
+
+	// scaling to zero does not stop the group
+	require.Nil(t, scheduler.ScaleGroup("foo", 0))
+	assert.Equal(t, 3, driver.KillTaskCount)
+	assert.Empty(t, group.Consumers)
+	assert.False(t, group.Stopped)
+
+	require.Nil(t, scheduler.ScaleGroup("foo", 1))
+	assert.Len(t, group.PendingConsumers(), 1)
+
+	// new consumers of a stopped group should not be scheduled
+	require.Nil(t, scheduler.StopGroup("foo"))
+	require.Nil(t, scheduler.ScaleGroup("foo", 2))
+	assert.Len(t, group.ConsumersWithState(ConsumerStateStopped), 2)
+}
+
+func TestStartStoppingGroup(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+	scheduler.Registered(driver, util.NewFrameworkID("framework"), util.NewMasterInfo("master", 0, 5050))
+
+	group := newTestGroup("foo")
+	scheduler.Cluster().AddGroup(group)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	consumer := group.Consumers[0]
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(consumer.TaskID), mesos.TaskState_TASK_RUNNING))
+
+	require.Nil(t, scheduler.StopGroup("foo"))
+	require.Nil(t, scheduler.StartGroup("foo"))
+	assert.Equal(t, ConsumerStateStopping, consumer.State)
+
+	// consumer is relaunched only once its previous task has left the consumer group
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(consumer.TaskID), mesos.TaskState_TASK_KILLED))
+	assert.Equal(t, ConsumerStatePending, consumer.State)
+}
+
+func TestKillEscalation(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+	scheduler.Registered(driver, util.NewFrameworkID("framework"), util.NewMasterInfo("master", 0, 5050))
+
+	group := newTestGroup("foo")
+	group.KillGracePeriod = 10 * time.Millisecond
+	scheduler.Cluster().AddGroup(group)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	consumer := group.Consumers[0]
+	taskID := consumer.TaskID
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(taskID), mesos.TaskState_TASK_RUNNING))
+
+	// the executor learns the grace period from the kill policy
+	assert.Equal(t, int64(10*time.Millisecond), driver.LaunchedTasks[0].GetKillPolicy().GetGracePeriod().GetNanoseconds())
+
+	require.Nil(t, scheduler.StopGroup("foo"))
+	scheduler.lock.Lock()
+	assert.Equal(t, 1, driver.KillTaskCount)
+	scheduler.lock.Unlock()
+
+	// the task is killed again once the grace period expires
+	deadline := time.Now().Add(time.Second)
+	for {
+		scheduler.lock.Lock()
+		kills := driver.KillTaskCount
+		scheduler.lock.Unlock()
+		if kills == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	scheduler.lock.Lock()
+	defer scheduler.lock.Unlock()
+	assert.Equal(t, 2, driver.KillTaskCount)
+	assert.Equal(t, ConsumerStateStopping, consumer.State)
+	assert.Contains(t, consumer.Events[len(consumer.Events)-1].Message, "killed forcefully")
+
+	// terminated tasks are not escalated
+	consumer.Reset()
+	scheduler.escalateKill(taskID)
+	assert.Equal(t, 2, driver.KillTaskCount)
+}
+
+func TestKillEscalationOfRemovedGroup(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+	scheduler.Registered(driver, util.NewFrameworkID("framework"), util.NewMasterInfo("master", 0, 5050))
+
+	group := newTestGroup("foo")
+	group.KillGracePeriod = time.Hour
+	scheduler.Cluster().AddGroup(group)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	consumer := group.Consumers[0]
+	taskID := consumer.TaskID
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(taskID), mesos.TaskState_TASK_RUNNING))
+
+	// the group is gone, but its consumer is still tracked until its task terminates
+	require.Nil(t, scheduler.RemoveGroup("foo", true))
+	assert.False(t, scheduler.Cluster().ExistsGroup("foo"))
+	assert.Equal(t, []*Group{group}, scheduler.Cluster().GetStoppingGroups())
+	assert.Equal(t, 1, driver.KillTaskCount)
+
+	// and survives a failover, so the kill is escalated by the next scheduler instance
+	clusterJSON, err := json.Marshal(scheduler.Cluster())
+	require.Nil(t, err)
+	failedOver := NewGonsumerCluster()
+	require.Nil(t, json.Unmarshal(clusterJSON, failedOver))
+	_, stoppingConsumer := failedOver.GetConsumerByTaskID(taskID)
+	require.NotNil(t, stoppingConsumer)
+	assert.False(t, stoppingConsumer.KillTime.IsZero())
+
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(taskID), mesos.TaskState_TASK_KILLING))
+	assert.Equal(t, 1, driver.KillTaskCount)
+	assert.Equal(t, ConsumerStateStopping, consumer.State)
+
+	scheduler.lock.Lock()
+	scheduler.escalateKill(taskID)
+	scheduler.lock.Unlock()
+	assert.Equal(t, 2, driver.KillTaskCount)
+	assert.Contains(t, consumer.Events[len(consumer.Events)-1].Message, "killed forcefully")
+
+	// the group is forgotten once its consumers have stopped
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(taskID), mesos.TaskState_TASK_KILLED))
+	assert.Empty(t, scheduler.Cluster().GetStoppingGroups())
+	_, consumer = scheduler.Cluster().GetConsumerByTaskID(taskID)
+	assert.Nil(t, consumer)
+
+	scheduler.lock.Lock()
+	scheduler.escalateKill(taskID)
+	scheduler.lock.Unlock()
+	assert.Equal(t, 2, driver.KillTaskCount)
+}
+
+func TestGroupScaleRemovesPendingFirst(t *testing.T) {
+	group := newTestGroup("foo")
+	group.Scale(3)
+	group.Consumers[0].TaskID = "task-0"
+	group.Consumers[0].LaunchTime = time.Unix(10, 0)
+	group.Consumers[2].TaskID = "task-2"
+	group.Consumers[2].LaunchTime = time.Unix(1, 0)
+
+	removed := group.Scale(2)
+	require.Len(t, removed, 1)
+	assert.Equal(t, "1", removed[0].ID)
+	assert.Equal(t, "0", group.Consumers[0].ID)
+	assert.Equal(t, "2", group.Consumers[1].ID)
+
+	removed = group.Scale(1)
+	require.Len(t, removed, 1)
+	assert.Equal(t, "0", removed[0].ID)
+
+	group.Scale(2)
+	assert.Equal(t, "3", group.Consumers[1].ID)
+}
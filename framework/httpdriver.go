@@ -0,0 +1,578 @@
+package framework
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	"github.com/mesos/mesos-go/scheduler"
+	"github.com/yanzay/log"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	v1SchedulerPath     = "/api/v1/scheduler"
+	v1StreamIDHeader    = "Mesos-Stream-Id"
+	v1ContentType       = "application/json"
+	v1DefaultHeartbeat  = 15 * time.Second
+	v1MissedHeartbeats  = 5
+	v1ResubscribeDelay  = 2 * time.Second
+	v1MaxRedirects      = 10
+	v1MaxRecordIOLength = 64 * 1024 * 1024
+	// v1CallTimeout bounds calls other than SUBSCRIBE, whose response is the long-lived event stream.
+	v1CallTimeout = 10 * time.Second
+)
+
+// HTTPSchedulerDriver drives a scheduler through Mesos v1 HTTP Scheduler API. Unlike the libprocess driver
+// it only makes outgoing connections to the master, so it works behind NAT.
+type HTTPSchedulerDriver struct {
+	scheduler  scheduler.Scheduler
+	framework  *mesos.FrameworkInfo
+	credential *mesos.Credential
+	client     *http.Client
+	// callTimeout bounds a call, including redirects, so an unresponsive master does not block the scheduler.
+	callTimeout time.Duration
+
+	lock     sync.Mutex
+	master   string
+	streamID string
+	status   mesos.Status
+	// subscribed is set while the event stream is open, registered once the driver has subscribed at least once.
+	subscribed bool
+	registered bool
+	body       io.Closer
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// v1Event is a v1 scheduler event with v0 payloads.
+type v1Event struct {
+	Type       string
+	Subscribed *struct {
+		FrameworkId              *mesos.FrameworkID
+		HeartbeatIntervalSeconds *float64
+		MasterInfo               *mesos.MasterInfo
+	}
+	Offers *struct {
+		Offers []*mesos.Offer
+	}
+	Rescind *struct {
+		OfferId *mesos.OfferID
+	}
+	Update *struct {
+		Status *mesos.TaskStatus
+	}
+	Message *struct {
+		SlaveId    *mesos.SlaveID
+		ExecutorId *mesos.ExecutorID
+		Data       []byte
+	}
+	Failure *struct {
+		SlaveId    *mesos.SlaveID
+		ExecutorId *mesos.ExecutorID
+		Status     *int32
+	}
+	Error *struct {
+		Message *string
+	}
+}
+
+// NewHTTPSchedulerDriver creates a driver talking to a master at a given host:port. Credential is optional
+// and is used for HTTP basic authentication.
+func NewHTTPSchedulerDriver(sched scheduler.Scheduler, framework *mesos.FrameworkInfo, master string, credential *mesos.Credential) (*HTTPSchedulerDriver, error) {
+	if strings.HasPrefix(master, zkMasterPrefix) {
+		return nil, ErrHTTPMasterZk
+	}
+
+	return &HTTPSchedulerDriver{
+		scheduler:  sched,
+		framework:  framework,
+		credential: credential,
+		client: &http.Client{
+			// redirects to the leading master are followed manually to keep track of it
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		callTimeout: v1CallTimeout,
+		master:      strings.TrimSuffix(strings.TrimPrefix(master, "http://"), "/"),
+		status:      mesos.Status_DRIVER_NOT_STARTED,
+	}, nil
+}
+
+func (d *HTTPSchedulerDriver) Start() (mesos.Status, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.status != mesos.Status_DRIVER_NOT_STARTED {
+		return d.status, ErrDriverStarted
+	}
+
+	d.status = mesos.Status_DRIVER_RUNNING
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	go d.subscribeLoop()
+
+	return d.status, nil
+}
+
+func (d *HTTPSchedulerDriver) Stop(failover bool) (mesos.Status, error) {
+	if !failover {
+		// v0 driver semantics: stopping without failover unregisters the framework
+		_, err := d.call("TEARDOWN", nil)
+		if err != nil {
+			log.Errorf("Failed to tear down framework: %s", err)
+		}
+	}
+
+	return d.halt(mesos.Status_DRIVER_STOPPED)
+}
+
+func (d *HTTPSchedulerDriver) Abort() (mesos.Status, error) {
+	return d.halt(mesos.Status_DRIVER_ABORTED)
+}
+
+func (d *HTTPSchedulerDriver) Join() (mesos.Status, error) {
+	d.lock.Lock()
+	done := d.done
+	status := d.status
+	d.lock.Unlock()
+
+	if done == nil {
+		return status, ErrDriverNotRunning
+	}
+	<-done
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.status, nil
+}
+
+func (d *HTTPSchedulerDriver) Run() (mesos.Status, error) {
+	status, err := d.Start()
+	if err != nil {
+		return status, err
+	}
+
+	return d.Join()
+}
+
+func (d *HTTPSchedulerDriver) RequestResources(requests []*mesos.Request) (mesos.Status, error) {
+	return d.call("REQUEST", map[string]interface{}{
+		"requests": v1Marshal(requests),
+	})
+}
+
+func (d *HTTPSchedulerDriver) LaunchTasks(offerIDs []*mesos.OfferID, tasks []*mesos.TaskInfo, filters *mesos.Filters) (mesos.Status, error) {
+	operations := make([]interface{}, 0)
+	if len(tasks) > 0 {
+		operations = append(operations, map[string]interface{}{
+			"type": "LAUNCH",
+			"launch": map[string]interface{}{
+				"task_infos": v1Marshal(tasks),
+			},
+		})
+	}
+
+	accept := map[string]interface{}{
+		"offer_ids":  v1Marshal(offerIDs),
+		"operations": operations,
+	}
+	if filters != nil {
+		accept["filters"] = v1Marshal(filters)
+	}
+
+	return d.call("ACCEPT", accept)
+}
+
+func (d *HTTPSchedulerDriver) KillTask(taskID *mesos.TaskID) (mesos.Status, error) {
+	return d.call("KILL", map[string]interface{}{
+		"task_id": v1Marshal(taskID),
+	})
+}
+
+func (d *HTTPSchedulerDriver) DeclineOffer(offerID *mesos.OfferID, filters *mesos.Filters) (mesos.Status, error) {
+	decline := map[string]interface{}{
+		"offer_ids": []interface{}{v1Marshal(offerID)},
+	}
+	if filters != nil {
+		decline["filters"] = v1Marshal(filters)
+	}
+
+	return d.call("DECLINE", decline)
+}
+
+func (d *HTTPSchedulerDriver) ReviveOffers() (mesos.Status, error) {
+	return d.call("REVIVE", nil)
+}
+
+// SuppressOffers is supported by v1 API natively, so the scheduler does not have to fall back to long decline filters.
+func (d *HTTPSchedulerDriver) SuppressOffers() (mesos.Status, error) {
+	return d.call("SUPPRESS", nil)
+}
+
+func (d *HTTPSchedulerDriver) SendFrameworkMessage(executorID *mesos.ExecutorID, slaveID *mesos.SlaveID, data string) (mesos.Status, error) {
+	return d.call("MESSAGE", map[string]interface{}{
+		"agent_id":    v1Marshal(slaveID),
+		"executor_id": v1Marshal(executorID),
+		"data":        []byte(data),
+	})
+}
+
+func (d *HTTPSchedulerDriver) ReconcileTasks(statuses []*mesos.TaskStatus) (mesos.Status, error) {
+	tasks := make([]interface{}, 0, len(statuses))
+	for _, status := range statuses {
+		task := map[string]interface{}{
+			"task_id": v1Marshal(status.GetTaskId()),
+		}
+		if status.GetSlaveId() != nil {
+			task["agent_id"] = v1Marshal(status.GetSlaveId())
+		}
+		tasks = append(tasks, task)
+	}
+
+	return d.call("RECONCILE", map[string]interface{}{
+		"tasks": tasks,
+	})
+}
+
+// Master returns host:port of the master the driver currently talks to.
+func (d *HTTPSchedulerDriver) Master() string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.master
+}
+
+func (d *HTTPSchedulerDriver) halt(status mesos.Status) (mesos.Status, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.status != mesos.Status_DRIVER_RUNNING {
+		return d.status, ErrDriverNotRunning
+	}
+
+	d.status = status
+	close(d.stop)
+	if d.body != nil {
+		d.body.Close()
+	}
+
+	return d.status, nil
+}
+
+func (d *HTTPSchedulerDriver) running() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.status == mesos.Status_DRIVER_RUNNING
+}
+
+// subscribeLoop keeps the framework subscribed until the driver is stopped or aborted.
+func (d *HTTPSchedulerDriver) subscribeLoop() {
+	defer close(d.done)
+
+	for d.running() {
+		err := d.subscribe()
+		if !d.running() {
+			return
+		}
+
+		d.lock.Lock()
+		wasSubscribed := d.subscribed
+		d.subscribed = false
+		d.streamID = ""
+		d.lock.Unlock()
+
+		log.Warningf("Subscription to master %s ended: %v", d.Master(), err)
+		if wasSubscribed {
+			d.scheduler.Disconnected(d)
+		}
+
+		select {
+		case <-d.stop:
+			return
+		case <-time.After(v1ResubscribeDelay):
+		}
+	}
+}
+
+// subscribe opens the event stream and dispatches events until it breaks.
+func (d *HTTPSchedulerDriver) subscribe() error {
+	d.lock.Lock()
+	subscribe := map[string]interface{}{
+		"type": "SUBSCRIBE",
+		"subscribe": map[string]interface{}{
+			"framework_info": v1Marshal(d.framework),
+		},
+	}
+	if d.framework.GetId() != nil {
+		subscribe["framework_id"] = v1Marshal(d.framework.GetId())
+	}
+	d.lock.Unlock()
+
+	response, err := d.post(context.Background(), subscribe, "")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return responseError(response)
+	}
+
+	d.lock.Lock()
+	d.streamID = response.Header.Get(v1StreamIDHeader)
+	d.body = response.Body
+	if d.status != mesos.Status_DRIVER_RUNNING {
+		// halted while subscribing
+		d.lock.Unlock()
+		return ErrDriverNotRunning
+	}
+	d.lock.Unlock()
+
+	// the stream is considered broken once the master misses several heartbeats in a row
+	heartbeat := v1DefaultHeartbeat
+	watchdog := time.AfterFunc(v1MissedHeartbeats*heartbeat, func() {
+		log.Warning("Missed heartbeats from master, closing event stream")
+		response.Body.Close()
+	})
+	defer watchdog.Stop()
+
+	reader := bufio.NewReader(response.Body)
+	for {
+		record, err := readRecord(reader)
+		if err != nil {
+			return err
+		}
+
+		event := new(v1Event)
+		err = v1Unmarshal(record, event)
+		if err != nil {
+			log.Errorf("Failed to decode event %s: %s", record, err)
+			continue
+		}
+
+		if event.Subscribed != nil && event.Subscribed.HeartbeatIntervalSeconds != nil {
+			heartbeat = time.Duration(*event.Subscribed.HeartbeatIntervalSeconds * float64(time.Second))
+		}
+		watchdog.Reset(v1MissedHeartbeats * heartbeat)
+
+		d.handleEvent(event)
+	}
+}
+
+func (d *HTTPSchedulerDriver) handleEvent(event *v1Event) {
+	switch event.Type {
+	case "SUBSCRIBED":
+		if event.Subscribed == nil {
+			return
+		}
+
+		d.lock.Lock()
+		// the first subscription of the driver is a registration even when failing over to an existing framework ID
+		reregistered := d.registered
+		d.framework.Id = event.Subscribed.FrameworkId
+		d.subscribed = true
+		d.registered = true
+		d.lock.Unlock()
+
+		masterInfo := event.Subscribed.MasterInfo
+		if masterInfo == nil {
+			masterInfo = &mesos.MasterInfo{}
+		}
+
+		if reregistered {
+			d.scheduler.Reregistered(d, masterInfo)
+		} else {
+			d.scheduler.Registered(d, event.Subscribed.FrameworkId, masterInfo)
+		}
+	case "OFFERS":
+		if event.Offers != nil {
+			d.scheduler.ResourceOffers(d, event.Offers.Offers)
+		}
+	case "RESCIND":
+		if event.Rescind != nil {
+			d.scheduler.OfferRescinded(d, event.Rescind.OfferId)
+		}
+	case "UPDATE":
+		if event.Update == nil {
+			return
+		}
+
+		status := event.Update.Status
+		d.scheduler.StatusUpdate(d, status)
+		if len(status.GetUuid()) > 0 {
+			d.acknowledge(status)
+		}
+	case "MESSAGE":
+		if event.Message != nil {
+			d.scheduler.FrameworkMessage(d, event.Message.ExecutorId, event.Message.SlaveId, string(event.Message.Data))
+		}
+	case "FAILURE":
+		if event.Failure == nil {
+			return
+		}
+
+		if event.Failure.ExecutorId != nil {
+			exitStatus := 0
+			if event.Failure.Status != nil {
+				exitStatus = int(*event.Failure.Status)
+			}
+			d.scheduler.ExecutorLost(d, event.Failure.ExecutorId, event.Failure.SlaveId, exitStatus)
+		} else {
+			d.scheduler.SlaveLost(d, event.Failure.SlaveId)
+		}
+	case "ERROR":
+		message := ""
+		if event.Error != nil && event.Error.Message != nil {
+			message = *event.Error.Message
+		}
+		d.scheduler.Error(d, message)
+
+		// master closes the stream after an error, the framework is not usable anymore
+		d.Abort()
+	case "HEARTBEAT":
+	default:
+		log.Warningf("Ignoring unknown event %s", event.Type)
+	}
+}
+
+func (d *HTTPSchedulerDriver) acknowledge(status *mesos.TaskStatus) {
+	_, err := d.call("ACKNOWLEDGE", map[string]interface{}{
+		"agent_id": v1Marshal(status.GetSlaveId()),
+		"task_id":  v1Marshal(status.GetTaskId()),
+		"uuid":     status.GetUuid(),
+	})
+	if err != nil {
+		log.Errorf("Failed to acknowledge status update for task %s: %s", status.GetTaskId().GetValue(), err)
+	}
+}
+
+// call sends a call of a given type with a given payload and expects it to be accepted.
+func (d *HTTPSchedulerDriver) call(callType string, payload map[string]interface{}) (mesos.Status, error) {
+	d.lock.Lock()
+	status := d.status
+	streamID := d.streamID
+	call := map[string]interface{}{
+		"type":         callType,
+		"framework_id": v1Marshal(d.framework.GetId()),
+	}
+	d.lock.Unlock()
+
+	if status != mesos.Status_DRIVER_RUNNING {
+		return status, ErrDriverNotRunning
+	}
+
+	if streamID == "" {
+		return status, ErrNotSubscribed
+	}
+
+	if payload != nil {
+		call[strings.ToLower(callType)] = payload
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.callTimeout)
+	defer cancel()
+
+	response, err := d.post(ctx, call, streamID)
+	if err != nil {
+		return status, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusAccepted && response.StatusCode != http.StatusOK {
+		return status, responseError(response)
+	}
+
+	return status, nil
+}
+
+// post sends a call to the current master following redirects to the leading master. The call is cancelled
+// once a given context is done.
+func (d *HTTPSchedulerDriver) post(ctx context.Context, call map[string]interface{}, streamID string) (*http.Response, error) {
+	body, err := json.Marshal(call)
+	if err != nil {
+		return nil, err
+	}
+
+	for redirects := 0; redirects < v1MaxRedirects; redirects++ {
+		master := d.Master()
+		request, err := http.NewRequest("POST", "http://"+master+v1SchedulerPath, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		request = request.WithContext(ctx)
+
+		request.Header.Set("Content-Type", v1ContentType)
+		request.Header.Set("Accept", v1ContentType)
+		if streamID != "" {
+			request.Header.Set(v1StreamIDHeader, streamID)
+		}
+		if d.credential != nil {
+			request.SetBasicAuth(d.credential.GetPrincipal(), d.credential.GetSecret())
+		}
+
+		response, err := d.client.Do(request)
+		if err != nil {
+			return nil, err
+		}
+
+		if response.StatusCode != http.StatusTemporaryRedirect {
+			return response, nil
+		}
+		response.Body.Close()
+
+		// Location looks like //host:port/api/v1/scheduler
+		location, err := url.Parse(response.Header.Get("Location"))
+		if err != nil || location.Host == "" {
+			return nil, fmt.Errorf("Invalid redirect location %s from master %s", response.Header.Get("Location"), master)
+		}
+
+		log.Infof("Master %s redirected to leading master %s", master, location.Host)
+		d.lock.Lock()
+		d.master = location.Host
+		d.lock.Unlock()
+	}
+
+	return nil, fmt.Errorf("Too many redirects trying to reach leading master")
+}
+
+func responseError(response *http.Response) error {
+	body, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("Authentication failed: %s %s", response.Status, strings.TrimSpace(string(body)))
+	}
+
+	return fmt.Errorf("Master responded with %s: %s", response.Status, strings.TrimSpace(string(body)))
+}
+
+// readRecord reads a single RecordIO record, i.e. its length in bytes followed by a newline and the record itself.
+func readRecord(reader *bufio.Reader) ([]byte, error) {
+	rawLength, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.ParseUint(strings.TrimSpace(rawLength), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid RecordIO length %q: %s", rawLength, err)
+	}
+
+	if length > v1MaxRecordIOLength {
+		return nil, fmt.Errorf("RecordIO record of %d bytes is too large", length)
+	}
+
+	record := make([]byte, length)
+	_, err = io.ReadFull(reader, record)
+	return record, err
+}
@@ -0,0 +1,280 @@
+package framework
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	util "github.com/mesos/mesos-go/mesosutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeMaster serves v1 Scheduler API. Events sent to it are streamed to the subscribed framework,
+// calls it receives are recorded.
+type fakeMaster struct {
+	server *httptest.Server
+	events chan map[string]interface{}
+	calls  chan map[string]interface{}
+	// disconnect closes the current event stream
+	disconnect chan struct{}
+}
+
+func newFakeMaster() *fakeMaster {
+	master := &fakeMaster{
+		events:     make(chan map[string]interface{}, 10),
+		calls:      make(chan map[string]interface{}, 100),
+		disconnect: make(chan struct{}, 1),
+	}
+	master.server = httptest.NewServer(http.HandlerFunc(master.handle))
+
+	return master
+}
+
+func (m *fakeMaster) host() string {
+	return strings.TrimPrefix(m.server.URL, "http://")
+}
+
+func (m *fakeMaster) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != v1SchedulerPath || r.Header.Get("Content-Type") != v1ContentType {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+
+	call := make(map[string]interface{})
+	err := json.NewDecoder(r.Body).Decode(&call)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m.calls <- call
+
+	if call["type"] != "SUBSCRIBE" {
+		if r.Header.Get(v1StreamIDHeader) != "stream" {
+			http.Error(w, "invalid stream id", http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set(v1StreamIDHeader, "stream")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+
+	for {
+		select {
+		case event := <-m.events:
+			record, err := json.Marshal(event)
+			if err != nil {
+				panic(err)
+			}
+
+			fmt.Fprintf(w, "%d\n%s", len(record), record)
+			w.(http.Flusher).Flush()
+		case <-m.disconnect:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// expectCall waits for a call of a given type skipping any other calls.
+func (m *fakeMaster) expectCall(t *testing.T, callType string) map[string]interface{} {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case call := <-m.calls:
+			if call["type"] == callType {
+				return call
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %s call", callType)
+		}
+	}
+}
+
+func subscribedEvent(frameworkID string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "SUBSCRIBED",
+		"subscribed": map[string]interface{}{
+			"framework_id":               map[string]interface{}{"value": frameworkID},
+			"heartbeat_interval_seconds": 15,
+			"master_info":                v1Marshal(util.NewMasterInfo("master", 0x0100007f, 5050)),
+		},
+	}
+}
+
+func newTestHTTPDriver(t *testing.T, scheduler *GonsumerScheduler, master string) *HTTPSchedulerDriver {
+	frameworkInfo := &mesos.FrameworkInfo{
+		User: proto.String("gonsumer"),
+		Name: proto.String("gonsumer"),
+	}
+
+	driver, err := NewHTTPSchedulerDriver(scheduler, frameworkInfo, master, nil)
+	require.Nil(t, err)
+
+	return driver
+}
+
+func TestHTTPSchedulerDriver(t *testing.T) {
+	master := newFakeMaster()
+	defer master.server.Close()
+
+	scheduler := newTestScheduler(t)
+	group := newTestGroup("foo")
+	group.Scale(1)
+	scheduler.Cluster().AddGroup(group)
+
+	driver := newTestHTTPDriver(t, scheduler, master.host())
+	_, err := driver.Start()
+	require.Nil(t, err)
+	defer driver.Abort()
+
+	subscribe := master.expectCall(t, "SUBSCRIBE")
+	assert.Nil(t, subscribe["framework_id"])
+	assert.Equal(t, "gonsumer", subscribe["subscribe"].(map[string]interface{})["framework_info"].(map[string]interface{})["name"])
+
+	master.events <- subscribedEvent("framework")
+	master.expectCall(t, "RECONCILE")
+	assert.True(t, scheduler.Status().Registered)
+	assert.Equal(t, "framework", scheduler.Status().FrameworkID)
+
+	// pending consumer is launched on the offer
+	master.events <- map[string]interface{}{
+		"type": "OFFERS",
+		"offers": map[string]interface{}{
+			"offers": []interface{}{v1Marshal(newTestOffer("1", 4, 4096))},
+		},
+	}
+	accept := master.expectCall(t, "ACCEPT")["accept"].(map[string]interface{})
+	assert.Equal(t, "1", accept["offer_ids"].([]interface{})[0].(map[string]interface{})["value"])
+	operation := accept["operations"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "LAUNCH", operation["type"])
+	task := operation["launch"].(map[string]interface{})["task_infos"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "slave-1", task["agent_id"].(map[string]interface{})["value"])
+
+	// every consumer is placed, so there is no need for more offers
+	master.expectCall(t, "SUPPRESS")
+
+	scheduler.lock.Lock()
+	taskID := group.Consumers[0].TaskID
+	scheduler.lock.Unlock()
+
+	// status updates are acknowledged after the scheduler has handled them
+	status := util.NewTaskStatus(util.NewTaskID(taskID), mesos.TaskState_TASK_RUNNING)
+	status.SlaveId = util.NewSlaveID("slave-1")
+	status.Uuid = []byte("uuid")
+	master.events <- map[string]interface{}{
+		"type":   "UPDATE",
+		"update": map[string]interface{}{"status": v1Marshal(status)},
+	}
+	acknowledge := master.expectCall(t, "ACKNOWLEDGE")["acknowledge"].(map[string]interface{})
+	assert.Equal(t, "dXVpZA==", acknowledge["uuid"])
+	assert.Equal(t, "slave-1", acknowledge["agent_id"].(map[string]interface{})["value"])
+
+	scheduler.lock.Lock()
+	assert.Equal(t, ConsumerStateRunning, group.Consumers[0].State)
+	scheduler.lock.Unlock()
+
+	// framework resubscribes with its ID once the stream breaks
+	master.disconnect <- struct{}{}
+	subscribe = master.expectCall(t, "SUBSCRIBE")
+	assert.Equal(t, "framework", subscribe["framework_id"].(map[string]interface{})["value"])
+	assert.False(t, scheduler.Status().Registered)
+
+	master.events <- subscribedEvent("framework")
+	master.expectCall(t, "RECONCILE")
+	assert.True(t, scheduler.Status().Registered)
+
+	_, err = driver.Stop(false)
+	assert.Nil(t, err)
+	master.expectCall(t, "TEARDOWN")
+
+	joinStatus, err := driver.Join()
+	assert.Nil(t, err)
+	assert.Equal(t, mesos.Status_DRIVER_STOPPED, joinStatus)
+}
+
+func TestHTTPSchedulerDriverRedirect(t *testing.T) {
+	master := newFakeMaster()
+	defer master.server.Close()
+
+	standby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "//"+master.host()+v1SchedulerPath)
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	}))
+	defer standby.Close()
+
+	driver := newTestHTTPDriver(t, newTestScheduler(t), strings.TrimPrefix(standby.URL, "http://"))
+	_, err := driver.Start()
+	require.Nil(t, err)
+	defer driver.Abort()
+
+	master.expectCall(t, "SUBSCRIBE")
+	assert.Equal(t, master.host(), driver.Master())
+}
+
+func TestHTTPSchedulerDriverError(t *testing.T) {
+	master := newFakeMaster()
+	defer master.server.Close()
+
+	scheduler := newTestScheduler(t)
+	driver := newTestHTTPDriver(t, scheduler, master.host())
+	_, err := driver.Start()
+	require.Nil(t, err)
+
+	master.expectCall(t, "SUBSCRIBE")
+	master.events <- map[string]interface{}{
+		"type":  "ERROR",
+		"error": map[string]interface{}{"message": "Framework has been removed"},
+	}
+
+	status, err := driver.Join()
+	assert.Nil(t, err)
+	assert.Equal(t, mesos.Status_DRIVER_ABORTED, status)
+	assert.Equal(t, "Framework has been removed", scheduler.Status().Error)
+
+	_, err = driver.KillTask(util.NewTaskID("task"))
+	assert.Equal(t, ErrDriverNotRunning, err)
+}
+
+func TestHTTPSchedulerDriverCallTimeout(t *testing.T) {
+	// accepts calls but does not answer them until the test ends
+	release := make(chan struct{})
+	master := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer master.Close()
+	defer close(release)
+
+	driver := newTestHTTPDriver(t, newTestScheduler(t), strings.TrimPrefix(master.URL, "http://"))
+	driver.callTimeout = 100 * time.Millisecond
+	driver.status = mesos.Status_DRIVER_RUNNING
+	driver.streamID = "stream"
+
+	killed := make(chan error, 1)
+	go func() {
+		_, err := driver.KillTask(util.NewTaskID("task"))
+		killed <- err
+	}()
+
+	select {
+	case err := <-killed:
+		assert.NotNil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the call to time out")
+	}
+}
+
+func TestHTTPSchedulerDriverZkMaster(t *testing.T) {
+	_, err := NewHTTPSchedulerDriver(newTestScheduler(t), &mesos.FrameworkInfo{}, "zk://zk:2181/mesos", nil)
+	assert.Equal(t, ErrHTTPMasterZk, err)
+}
@@ -0,0 +1,224 @@
+package framework
+
+import (
+	"github.com/samuel/go-zookeeper/zk"
+	"github.com/yanzay/log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LeaderElection makes sure only one of several scheduler instances drives the framework at a time.
+type LeaderElection interface {
+	// Campaign blocks until this instance becomes the leader.
+	Campaign() error
+	// IsLeader returns true if this instance is the leader.
+	IsLeader() bool
+	// Leader returns the API address of the current leader or an empty string if there is none.
+	Leader() (string, error)
+	// Lost is closed once this instance loses leadership.
+	Lost() <-chan struct{}
+	// Resign gives up leadership or candidacy.
+	Resign() error
+}
+
+const (
+	leaderElectionNode   = "leader"
+	leaderElectionPrefix = "member-"
+	zkSessionTimeout     = 10 * time.Second
+)
+
+// ZKLeaderElection elects a leader among instances holding ephemeral sequential nodes under a common ZooKeeper path.
+// The instance with the lowest sequence number is the leader, every other instance watches its predecessor.
+type ZKLeaderElection struct {
+	zkConnect string
+	zPath     string
+	id        string
+
+	node string
+
+	// lock guards conn and leader, conn is read by API handlers while Campaign establishes it.
+	lock     sync.Mutex
+	conn     *zk.Conn
+	leader   bool
+	lost     chan struct{}
+	lostOnce sync.Once
+}
+
+// NewZKLeaderElection creates an election at a given host:port/path. Id is the API address advertised by this instance.
+func NewZKLeaderElection(zkConnect string, id string) *ZKLeaderElection {
+	connect, path := splitZkConnect(zkConnect)
+	return &ZKLeaderElection{
+		zkConnect: connect,
+		zPath:     strings.TrimSuffix(path, "/") + "/" + leaderElectionNode,
+		id:        id,
+		lost:      make(chan struct{}),
+	}
+}
+
+func (e *ZKLeaderElection) Campaign() error {
+	conn, events, err := zk.Connect([]string{e.zkConnect}, zkSessionTimeout)
+	if err != nil {
+		return err
+	}
+	e.lock.Lock()
+	e.conn = conn
+	e.lock.Unlock()
+	go e.watchSession(events)
+
+	err = createZPath(conn, e.zPath)
+	if err != nil {
+		return err
+	}
+
+	node, err := conn.Create(e.zPath+"/"+leaderElectionPrefix, []byte(e.id), zk.FlagEphemeral|zk.FlagSequence, zk.WorldACL(zk.PermAll))
+	if err != nil {
+		return err
+	}
+	e.node = node[strings.LastIndex(node, "/")+1:]
+	log.Infof("Joined leader election at %s as %s", e.zPath, e.node)
+
+	for {
+		members, err := e.members(conn)
+		if err != nil {
+			return err
+		}
+
+		index := sort.SearchStrings(members, e.node)
+		if index == len(members) || members[index] != e.node {
+			return ErrLeadershipLost
+		}
+
+		if index == 0 {
+			e.lock.Lock()
+			e.leader = true
+			e.lock.Unlock()
+
+			go e.watchNode(conn)
+			return nil
+		}
+
+		predecessor := e.zPath + "/" + members[index-1]
+		exists, _, predecessorEvents, err := conn.ExistsW(predecessor)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			continue
+		}
+
+		log.Infof("Waiting for %s to go away", predecessor)
+		select {
+		case <-predecessorEvents:
+		case <-e.lost:
+			return ErrLeadershipLost
+		}
+	}
+}
+
+func (e *ZKLeaderElection) IsLeader() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.leader
+}
+
+func (e *ZKLeaderElection) Leader() (string, error) {
+	e.lock.Lock()
+	conn := e.conn
+	e.lock.Unlock()
+
+	if conn == nil {
+		return "", nil
+	}
+
+	members, err := e.members(conn)
+	if err != nil || len(members) == 0 {
+		return "", err
+	}
+
+	data, _, err := conn.Get(e.zPath + "/" + members[0])
+	if err == zk.ErrNoNode {
+		return "", nil
+	}
+
+	return string(data), err
+}
+
+func (e *ZKLeaderElection) Lost() <-chan struct{} {
+	return e.lost
+}
+
+func (e *ZKLeaderElection) Resign() error {
+	e.lock.Lock()
+	e.leader = false
+	conn := e.conn
+	e.conn = nil
+	e.lock.Unlock()
+
+	if conn != nil {
+		// closing the session removes the ephemeral node
+		conn.Close()
+	}
+
+	e.loseLeadership()
+	return nil
+}
+
+// members returns sorted names of election nodes. Sequence numbers are zero padded, so they sort as strings.
+func (e *ZKLeaderElection) members(conn *zk.Conn) ([]string, error) {
+	children, _, err := conn.Children(e.zPath)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]string, 0, len(children))
+	for _, child := range children {
+		if strings.HasPrefix(child, leaderElectionPrefix) {
+			members = append(members, child)
+		}
+	}
+	sort.Strings(members)
+
+	return members, nil
+}
+
+// watchNode gives up leadership once the node of this instance disappears.
+func (e *ZKLeaderElection) watchNode(conn *zk.Conn) {
+	for {
+		exists, _, events, err := conn.ExistsW(e.zPath + "/" + e.node)
+		if err != nil || !exists {
+			log.Warningf("Leader election node %s is gone: %v", e.node, err)
+			e.loseLeadership()
+			return
+		}
+
+		select {
+		case <-events:
+		case <-e.lost:
+			return
+		}
+	}
+}
+
+// watchSession gives up leadership once ZooKeeper session expires as ephemeral nodes are gone along with it.
+func (e *ZKLeaderElection) watchSession(events <-chan zk.Event) {
+	for event := range events {
+		if event.State == zk.StateExpired {
+			log.Warning("ZooKeeper session expired")
+			e.loseLeadership()
+			return
+		}
+	}
+}
+
+func (e *ZKLeaderElection) loseLeadership() {
+	e.lostOnce.Do(func() {
+		e.lock.Lock()
+		e.leader = false
+		e.lock.Unlock()
+		close(e.lost)
+	})
+}
@@ -0,0 +1,93 @@
+package framework
+
+import (
+	"fmt"
+	"github.com/samuel/go-zookeeper/zk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestZKLeaderElection(t *testing.T) {
+	zkConnect := "localhost:2181"
+
+	conn, _, err := zk.Connect([]string{zkConnect}, 30*time.Second)
+	_, _, err = conn.Exists("/tmp") // check if zk is alive
+	if err != nil {
+		t.Skipf("localhost:2181 is not responding (error %s). To run this test please spin up ZK on localhost:2181", err)
+	}
+	conn.Close()
+
+	zkPath := fmt.Sprintf("%s/tmp/zk/election-%d", zkConnect, time.Now().UnixNano())
+	first := NewZKLeaderElection(zkPath, "first:6666")
+	second := NewZKLeaderElection(zkPath, "second:6666")
+
+	require.Nil(t, first.Campaign())
+	assert.True(t, first.IsLeader())
+
+	campaign := make(chan error, 1)
+	go func() {
+		campaign <- second.Campaign()
+	}()
+
+	// second instance stays a standby while the first one holds leadership
+	select {
+	case err := <-campaign:
+		t.Fatalf("Standby became leader while leader is alive: %v", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+	assert.False(t, second.IsLeader())
+	leader, err := second.Leader()
+	require.Nil(t, err)
+	assert.Equal(t, "first:6666", leader)
+
+	require.Nil(t, first.Resign())
+	select {
+	case <-first.Lost():
+	default:
+		t.Fatal("Resigned instance should lose leadership")
+	}
+
+	select {
+	case err := <-campaign:
+		require.Nil(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Standby didn't take over after leader resigned")
+	}
+	assert.True(t, second.IsLeader())
+	leader, err = second.Leader()
+	require.Nil(t, err)
+	assert.Equal(t, "second:6666", leader)
+
+	require.Nil(t, second.Resign())
+}
+
+func TestZKLeaderElectionConcurrentLeader(t *testing.T) {
+	// nothing listens there, so campaigning fails once connection attempts are exhausted
+	election := NewZKLeaderElection(fmt.Sprintf("%s/gonsumer", freeAddress(t)), "first:6666")
+
+	campaign := make(chan error, 1)
+	go func() {
+		campaign <- election.Campaign()
+	}()
+
+	// API handlers ask for the leader while the session is being established
+	for done := false; !done; {
+		select {
+		case err := <-campaign:
+			assert.NotNil(t, err)
+			done = true
+		case <-time.After(10 * time.Second):
+			t.Fatal("Campaign didn't fail without ZooKeeper")
+		default:
+			election.Leader()
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	require.Nil(t, election.Resign())
+	leader, err := election.Leader()
+	assert.Nil(t, err)
+	assert.Empty(t, leader)
+}
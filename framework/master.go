@@ -0,0 +1,64 @@
+package framework
+
+import (
+	"encoding/binary"
+	"fmt"
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	"net"
+	"strings"
+)
+
+// zkMasterPrefix denotes a master URL in form zk://host1:port1,host2:port2/path
+// that is resolved to the leading master through ZooKeeper.
+const zkMasterPrefix = "zk://"
+
+// FrameworkStatus describes registration of the framework with Mesos master.
+type FrameworkStatus struct {
+	FrameworkID string `json:"framework_id"`
+	Registered  bool   `json:"registered"`
+	// Master is the configured master address, either host:port or a zk:// URL.
+	Master string `json:"master"`
+	// LeadingMaster is the address of the master the framework is currently registered with.
+	LeadingMaster string `json:"leading_master"`
+	// Principal the framework authenticates as, empty if authentication is disabled.
+	Principal string `json:"principal,omitempty"`
+	// Error is the last error reported by the driver, e.g. failed authentication.
+	Error string `json:"error,omitempty"`
+}
+
+// ValidateMaster checks a master is given either as host:port or as zk://hosts/path.
+func ValidateMaster(master string) error {
+	if strings.HasPrefix(master, zkMasterPrefix) {
+		hostsPath := strings.TrimPrefix(master, zkMasterPrefix)
+		slashIdx := strings.Index(hostsPath, "/")
+		if slashIdx <= 0 || slashIdx == len(hostsPath)-1 {
+			return fmt.Errorf("Invalid master %s, expected zk://host:port[,host:port]/path", master)
+		}
+
+		return nil
+	}
+
+	_, _, err := net.SplitHostPort(master)
+	if err != nil {
+		return fmt.Errorf("Invalid master %s, expected <ip>:<port> or zk://host:port[,host:port]/path", master)
+	}
+
+	return nil
+}
+
+// masterAddress returns host:port of a given master preferring its hostname over IP.
+func masterAddress(master *mesos.MasterInfo) string {
+	if master == nil {
+		return ""
+	}
+
+	host := master.GetHostname()
+	if host == "" {
+		// IP is stored in network byte order
+		ip := make(net.IP, net.IPv4len)
+		binary.LittleEndian.PutUint32(ip, master.GetIp())
+		host = ip.String()
+	}
+
+	return net.JoinHostPort(host, fmt.Sprint(master.GetPort()))
+}
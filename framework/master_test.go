@@ -0,0 +1,18 @@
+package framework
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidateMaster(t *testing.T) {
+	assert.Nil(t, ValidateMaster("127.0.0.1:5050"))
+	assert.Nil(t, ValidateMaster("master.example.com:5050"))
+	assert.Nil(t, ValidateMaster("zk://zk1:2181/mesos"))
+	assert.Nil(t, ValidateMaster("zk://zk1:2181,zk2:2181,zk3:2181/mesos"))
+
+	assert.NotNil(t, ValidateMaster("127.0.0.1"))
+	assert.NotNil(t, ValidateMaster("zk://zk1:2181"))
+	assert.NotNil(t, ValidateMaster("zk://zk1:2181/"))
+	assert.NotNil(t, ValidateMaster("zk:///mesos"))
+}
@@ -0,0 +1,85 @@
+package framework
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	memStoresLock sync.Mutex
+	memStores     = make(map[string]*memStore)
+)
+
+// memStore holds contents shared by all in-memory storages with the same name.
+type memStore struct {
+	lock     sync.Mutex
+	contents []byte
+	// version is incremented on every write.
+	version int
+}
+
+// MemStorage keeps cluster state in memory of the running process, so it is lost once the process exits. Storages
+// created with the same name share contents, e.g. to let several scheduler instances in a test see each other's
+// state. Like other storages, writes fail with ErrStorageConflict if the contents were changed through another
+// storage since they were read last.
+type MemStorage struct {
+	name  string
+	store *memStore
+
+	lock sync.Mutex
+	// version is the version of the contents read or written last, noVersion until the contents are read.
+	version int
+}
+
+func NewMemStorage(name string) *MemStorage {
+	memStoresLock.Lock()
+	defer memStoresLock.Unlock()
+
+	store, exists := memStores[name]
+	if !exists {
+		store = &memStore{}
+		memStores[name] = store
+	}
+
+	return &MemStorage{
+		name:    name,
+		store:   store,
+		version: noVersion,
+	}
+}
+
+func (ms *MemStorage) Save(contents []byte) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	ms.store.lock.Lock()
+	defer ms.store.lock.Unlock()
+
+	if ms.version != noVersion && ms.version != ms.store.version {
+		return ErrStorageConflict
+	}
+
+	ms.store.contents = append([]byte(nil), contents...)
+	ms.store.version++
+	ms.version = ms.store.version
+	return nil
+}
+
+func (ms *MemStorage) Load() ([]byte, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	ms.store.lock.Lock()
+	defer ms.store.lock.Unlock()
+
+	ms.version = ms.store.version
+	if len(ms.store.contents) == 0 {
+		return nil, ErrStorageUninitialized
+	}
+
+	return append([]byte(nil), ms.store.contents...), nil
+}
+
+func (ms *MemStorage) String() string {
+	return fmt.Sprintf("mem:%s", ms.name)
+}
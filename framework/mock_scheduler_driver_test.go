@@ -11,6 +11,8 @@ type MockSchedulerDriver struct {
 
 	AbortStatus mesos.Status
 	AbortError  error
+	// Aborts receives a value every time the driver is aborted, possibly from a separate goroutine.
+	Aborts chan struct{}
 
 	JoinStatus mesos.Status
 	JoinError  error
@@ -23,6 +25,8 @@ type MockSchedulerDriver struct {
 
 	LaunchTasksStatus mesos.Status
 	LaunchTasksError  error
+	LaunchTasksCount  int
+	LaunchedTasks     []*mesos.TaskInfo
 
 	KillTaskStatus mesos.Status
 	KillTaskError  error
@@ -30,9 +34,16 @@ type MockSchedulerDriver struct {
 
 	DeclineOfferStatus mesos.Status
 	DeclineOfferError  error
+	DeclineOfferCount  int
+	DeclineFilters     []*mesos.Filters
 
 	ReviveOffersStatus mesos.Status
 	ReviveOffersError  error
+	ReviveOffersCount  int
+
+	SuppressOffersStatus mesos.Status
+	SuppressOffersError  error
+	SuppressOffersCount  int
 
 	SendFrameworkMessageStatus mesos.Status
 	SendFrameworkMessageError  error
@@ -47,6 +58,7 @@ func NewMockSchedulerDriver() *MockSchedulerDriver {
 		StartStatus:                mesos.Status_DRIVER_RUNNING,
 		StopStatus:                 mesos.Status_DRIVER_RUNNING,
 		AbortStatus:                mesos.Status_DRIVER_RUNNING,
+		Aborts:                     make(chan struct{}, 10),
 		JoinStatus:                 mesos.Status_DRIVER_RUNNING,
 		RunStatus:                  mesos.Status_DRIVER_RUNNING,
 		RequestResourcesStatus:     mesos.Status_DRIVER_RUNNING,
@@ -54,6 +66,7 @@ func NewMockSchedulerDriver() *MockSchedulerDriver {
 		KillTaskStatus:             mesos.Status_DRIVER_RUNNING,
 		DeclineOfferStatus:         mesos.Status_DRIVER_RUNNING,
 		ReviveOffersStatus:         mesos.Status_DRIVER_RUNNING,
+		SuppressOffersStatus:       mesos.Status_DRIVER_RUNNING,
 		SendFrameworkMessageStatus: mesos.Status_DRIVER_RUNNING,
 		ReconcileTasksStatus:       mesos.Status_DRIVER_RUNNING,
 	}
@@ -68,6 +81,7 @@ func (s *MockSchedulerDriver) Stop(failover bool) (mesos.Status, error) {
 }
 
 func (s *MockSchedulerDriver) Abort() (mesos.Status, error) {
+	s.Aborts <- struct{}{}
 	return s.AbortStatus, s.AbortError
 }
 
@@ -84,6 +98,8 @@ func (s *MockSchedulerDriver) RequestResources(requests []*mesos.Request) (mesos
 }
 
 func (s *MockSchedulerDriver) LaunchTasks(offerIDs []*mesos.OfferID, tasks []*mesos.TaskInfo, filters *mesos.Filters) (mesos.Status, error) {
+	s.LaunchTasksCount++
+	s.LaunchedTasks = append(s.LaunchedTasks, tasks...)
 	return s.LaunchTasksStatus, s.LaunchTasksError
 }
 
@@ -93,13 +109,21 @@ func (s *MockSchedulerDriver) KillTask(taskID *mesos.TaskID) (mesos.Status, erro
 }
 
 func (s *MockSchedulerDriver) DeclineOffer(offerID *mesos.OfferID, filters *mesos.Filters) (mesos.Status, error) {
+	s.DeclineOfferCount++
+	s.DeclineFilters = append(s.DeclineFilters, filters)
 	return s.DeclineOfferStatus, s.DeclineOfferError
 }
 
 func (s *MockSchedulerDriver) ReviveOffers() (mesos.Status, error) {
+	s.ReviveOffersCount++
 	return s.ReviveOffersStatus, s.ReviveOffersError
 }
 
+func (s *MockSchedulerDriver) SuppressOffers() (mesos.Status, error) {
+	s.SuppressOffersCount++
+	return s.SuppressOffersStatus, s.SuppressOffersError
+}
+
 func (s *MockSchedulerDriver) SendFrameworkMessage(executorID *mesos.ExecutorID, slaveID *mesos.SlaveID, data string) (mesos.Status, error) {
 	return s.SendFrameworkMessageStatus, s.SendFrameworkMessageError
 }
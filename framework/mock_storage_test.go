@@ -0,0 +1,36 @@
+package framework
+
+type MockStorage struct {
+	Contents []byte
+
+	SaveError error
+	SaveCount int
+
+	LoadError error
+}
+
+func NewMockStorage() *MockStorage {
+	return &MockStorage{}
+}
+
+func (s *MockStorage) Save(contents []byte) error {
+	s.SaveCount++
+	if s.SaveError != nil {
+		return s.SaveError
+	}
+
+	s.Contents = contents
+	return nil
+}
+
+func (s *MockStorage) Load() ([]byte, error) {
+	if s.LoadError != nil {
+		return nil, s.LoadError
+	}
+
+	if s.Contents == nil {
+		return nil, ErrStorageUninitialized
+	}
+
+	return s.Contents, nil
+}
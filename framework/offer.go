@@ -0,0 +1,195 @@
+package framework
+
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	util "github.com/mesos/mesos-go/mesosutil"
+)
+
+// resourceEpsilon is the smallest amount of a scalar resource worth allocating, smaller leftovers are rounding errors.
+const resourceEpsilon = 1e-6
+
+// offerResources keeps track of resources left in a single offer while consumers are being placed onto it.
+// Resources reserved for a role are allocated before unreserved ones and keep their role, so the master
+// accepts tasks launched on a role-reserved offer.
+type offerResources struct {
+	// scalars lists remaining amounts of scalar resources by name.
+	scalars map[string][]*scalarShare
+	// ports lists port ranges not allocated yet.
+	ports []portRange
+}
+
+// scalarShare is the amount left of a single offered scalar resource.
+type scalarShare struct {
+	resource *mesos.Resource
+	value    float64
+}
+
+type portRange struct {
+	begin uint64
+	end   uint64
+	// resource is the offered resource this range belongs to.
+	resource *mesos.Resource
+}
+
+func newOfferResources(offer *mesos.Offer) *offerResources {
+	resources := reservedFirst(offer.GetResources())
+	return &offerResources{
+		scalars: scalarResources(resources),
+		ports:   portResources(resources),
+	}
+}
+
+// matches returns an empty string if a consumer of a given group fits into remaining resources, otherwise a decline reason.
+func (r *offerResources) matches(group *Group) string {
+	if cpus := r.scalar("cpus"); cpus < group.Cpus {
+		return fmt.Sprintf("cpus %.2f < %.2f", cpus, group.Cpus)
+	}
+
+	if mem := r.scalar("mem"); mem < group.Mem {
+		return fmt.Sprintf("mem %.2f < %.2f", mem, group.Mem)
+	}
+
+	if disk := r.scalar("disk"); disk < group.Disk {
+		return fmt.Sprintf("disk %.2f < %.2f", disk, group.Disk)
+	}
+
+	if ports := r.availablePorts(); ports < uint64(group.Ports) {
+		return fmt.Sprintf("ports %d < %d", ports, group.Ports)
+	}
+
+	return ""
+}
+
+// consume takes resources for a consumer of a given group and returns them as task resources along with
+// the ports allocated for it.
+func (r *offerResources) consume(group *Group) ([]*mesos.Resource, []uint64) {
+	resources := make([]*mesos.Resource, 0)
+	resources = append(resources, r.consumeScalar("cpus", group.Cpus)...)
+	resources = append(resources, r.consumeScalar("mem", group.Mem)...)
+	resources = append(resources, r.consumeScalar("disk", group.Disk)...)
+
+	ports := make([]uint64, 0, group.Ports)
+	portRanges := make(map[*mesos.Resource][]*mesos.Value_Range)
+	portSources := make([]*mesos.Resource, 0)
+	for len(ports) < group.Ports && len(r.ports) > 0 {
+		port, source := r.ports[0].begin, r.ports[0].resource
+		ports = append(ports, port)
+		if _, exists := portRanges[source]; !exists {
+			portSources = append(portSources, source)
+		}
+		portRanges[source] = append(portRanges[source], util.NewValueRange(port, port))
+
+		if r.ports[0].begin == r.ports[0].end {
+			r.ports = r.ports[1:]
+		} else {
+			r.ports[0].begin++
+		}
+	}
+
+	for _, source := range portSources {
+		resources = append(resources, taskResource(util.NewRangesResource("ports", portRanges[source]), source))
+	}
+
+	return resources, ports
+}
+
+// consumeScalar takes a given amount of a scalar resource, possibly from several offered resources.
+func (r *offerResources) consumeScalar(name string, amount float64) []*mesos.Resource {
+	resources := make([]*mesos.Resource, 0)
+	for _, share := range r.scalars[name] {
+		if amount < resourceEpsilon {
+			break
+		}
+
+		if share.value < resourceEpsilon {
+			continue
+		}
+
+		value := amount
+		if share.value < value {
+			value = share.value
+		}
+		share.value -= value
+		amount -= value
+
+		resources = append(resources, taskResource(util.NewScalarResource(name, value), share.resource))
+	}
+
+	return resources
+}
+
+func (r *offerResources) scalar(name string) float64 {
+	value := 0.0
+	for _, share := range r.scalars[name] {
+		value += share.value
+	}
+
+	return value
+}
+
+func (r *offerResources) availablePorts() uint64 {
+	ports := uint64(0)
+	for _, ranges := range r.ports {
+		ports += ranges.end - ranges.begin + 1
+	}
+
+	return ports
+}
+
+// taskResource makes a given task resource allocated from the same role and reservation as an offered resource.
+func taskResource(resource *mesos.Resource, source *mesos.Resource) *mesos.Resource {
+	resource.Role = proto.String(source.GetRole())
+	resource.Reservation = source.GetReservation()
+	return resource
+}
+
+// reservedFirst returns given resources with resources reserved for a role ahead of unreserved ones.
+func reservedFirst(resources []*mesos.Resource) []*mesos.Resource {
+	sorted := make([]*mesos.Resource, 0, len(resources))
+	for _, resource := range resources {
+		if resource.GetRole() != "*" {
+			sorted = append(sorted, resource)
+		}
+	}
+
+	for _, resource := range resources {
+		if resource.GetRole() == "*" {
+			sorted = append(sorted, resource)
+		}
+	}
+
+	return sorted
+}
+
+func scalarResources(resources []*mesos.Resource) map[string][]*scalarShare {
+	scalars := make(map[string][]*scalarShare)
+	for _, resource := range resources {
+		if resource.GetScalar() == nil {
+			continue
+		}
+
+		scalars[resource.GetName()] = append(scalars[resource.GetName()], &scalarShare{
+			resource: resource,
+			value:    resource.GetScalar().GetValue(),
+		})
+	}
+
+	return scalars
+}
+
+func portResources(resources []*mesos.Resource) []portRange {
+	ports := make([]portRange, 0)
+	for _, resource := range resources {
+		if resource.GetName() != "ports" || resource.GetRanges() == nil {
+			continue
+		}
+
+		for _, ranges := range resource.GetRanges().GetRange() {
+			ports = append(ports, portRange{begin: ranges.GetBegin(), end: ranges.GetEnd(), resource: resource})
+		}
+	}
+
+	return ports
+}
@@ -5,6 +5,7 @@ import (
 	util "github.com/mesos/mesos-go/mesosutil"
 	"github.com/mesos/mesos-go/scheduler"
 	"github.com/yanzay/log"
+	"sort"
 	"sync"
 	"time"
 )
@@ -19,6 +20,14 @@ type Reconciler struct {
 	reconciles    int
 }
 
+// ReconcileStatus describes progress of explicit reconciliation.
+type ReconcileStatus struct {
+	Tries        int       `json:"tries"`
+	MaxTries     int       `json:"max_tries"`
+	PendingTasks []string  `json:"pending_tasks"`
+	LastRun      time.Time `json:"last_run"`
+}
+
 func NewReconciler() *Reconciler {
 	return &Reconciler{
 		ReconcileDelay:    10 * time.Second,
@@ -53,39 +62,82 @@ func (r *Reconciler) Update(status *mesos.TaskStatus) {
 	}
 }
 
+// Reset forgets tasks awaiting reconciliation and the number of tries so a new reconciliation can start immediately.
+func (r *Reconciler) Reset() {
+	r.taskLock.Lock()
+	defer r.taskLock.Unlock()
+
+	r.tasks = make(map[string]struct{})
+	r.reconciles = 0
+	r.reconcileTime = time.Unix(0, 0)
+}
+
+// Done returns true if every explicitly reconciled task has answered.
+func (r *Reconciler) Done() bool {
+	r.taskLock.Lock()
+	defer r.taskLock.Unlock()
+
+	return len(r.tasks) == 0
+}
+
+func (r *Reconciler) Status() *ReconcileStatus {
+	r.taskLock.Lock()
+	defer r.taskLock.Unlock()
+
+	status := &ReconcileStatus{
+		Tries:        r.reconciles,
+		MaxTries:     r.ReconcileMaxTries,
+		PendingTasks: make([]string, 0, len(r.tasks)),
+	}
+
+	if r.reconcileTime.After(time.Unix(0, 0)) {
+		status.LastRun = r.reconcileTime
+	}
+
+	for task := range r.tasks {
+		status.PendingTasks = append(status.PendingTasks, task)
+	}
+	sort.Strings(status.PendingTasks)
+
+	return status
+}
+
 func (r *Reconciler) reconcile(driver scheduler.SchedulerDriver, implicit bool) error {
-	if time.Now().Sub(r.reconcileTime) >= r.ReconcileDelay {
-		r.taskLock.Lock()
-		defer r.taskLock.Unlock()
+	// the delay is checked under the lock as the scheduler driver and the reconciliation loop reconcile concurrently
+	r.taskLock.Lock()
+	defer r.taskLock.Unlock()
 
-		r.reconciles++
-		r.reconcileTime = time.Now()
+	if time.Now().Sub(r.reconcileTime) < r.ReconcileDelay {
+		return nil
+	}
 
-		if r.reconciles > r.ReconcileMaxTries {
-			for task := range r.tasks {
-				log.Infof("Reconciling exceeded %d tries, sending killTask for task %s", r.ReconcileMaxTries, task)
-				_, err := driver.KillTask(util.NewTaskID(task))
-				if err != nil {
-					return err
-				}
+	r.reconciles++
+	r.reconcileTime = time.Now()
+
+	if r.reconciles > r.ReconcileMaxTries {
+		for task := range r.tasks {
+			log.Infof("Reconciling exceeded %d tries, sending killTask for task %s", r.ReconcileMaxTries, task)
+			_, err := driver.KillTask(util.NewTaskID(task))
+			if err != nil {
+				return err
+			}
+		}
+		r.reconciles = 0
+	} else {
+		if implicit {
+			_, err := driver.ReconcileTasks(nil)
+			if err != nil {
+				return err
 			}
-			r.reconciles = 0
 		} else {
-			if implicit {
-				_, err := driver.ReconcileTasks(nil)
-				if err != nil {
-					return err
-				}
-			} else {
-				statuses := make([]*mesos.TaskStatus, 0)
-				for task := range r.tasks {
-					log.Debugf("Reconciling %d/%d task state for task id %s", r.reconciles, r.ReconcileMaxTries, task)
-					statuses = append(statuses, util.NewTaskStatus(util.NewTaskID(task), mesos.TaskState_TASK_STAGING))
-				}
-				_, err := driver.ReconcileTasks(statuses)
-				if err != nil {
-					return err
-				}
+			statuses := make([]*mesos.TaskStatus, 0)
+			for task := range r.tasks {
+				log.Debugf("Reconciling %d/%d task state for task id %s", r.reconciles, r.ReconcileMaxTries, task)
+				statuses = append(statuses, util.NewTaskStatus(util.NewTaskID(task), mesos.TaskState_TASK_STAGING))
+			}
+			_, err := driver.ReconcileTasks(statuses)
+			if err != nil {
+				return err
 			}
 		}
 	}
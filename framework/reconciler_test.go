@@ -5,6 +5,7 @@ import (
 	mesos "github.com/mesos/mesos-go/mesosproto"
 	util "github.com/mesos/mesos-go/mesosutil"
 	"github.com/stretchr/testify/assert"
+	"sync"
 	"testing"
 	"time"
 )
@@ -89,3 +90,49 @@ func TestReconcilerUpdate(t *testing.T) {
 	assert.Len(t, reconciler.tasks, 0)
 	assert.Equal(t, 0, reconciler.reconciles) // reset
 }
+
+func TestReconcilerStatus(t *testing.T) {
+	reconciler := NewReconciler()
+	driver := NewMockSchedulerDriver()
+
+	status := reconciler.Status()
+	assert.Equal(t, 0, status.Tries)
+	assert.Equal(t, 3, status.MaxTries)
+	assert.Empty(t, status.PendingTasks)
+	assert.True(t, status.LastRun.IsZero())
+	assert.True(t, reconciler.Done())
+
+	err := reconciler.ExplicitReconcile([]string{"foo", "bar"}, driver)
+	assert.Nil(t, err)
+	assert.False(t, reconciler.Done())
+
+	status = reconciler.Status()
+	assert.Equal(t, 1, status.Tries)
+	assert.Equal(t, []string{"bar", "foo"}, status.PendingTasks)
+	assert.False(t, status.LastRun.IsZero())
+
+	reconciler.Update(util.NewTaskStatus(util.NewTaskID("foo"), mesos.TaskState_TASK_RUNNING))
+	reconciler.Update(util.NewTaskStatus(util.NewTaskID("bar"), mesos.TaskState_TASK_LOST))
+	assert.True(t, reconciler.Done())
+	assert.Empty(t, reconciler.Status().PendingTasks)
+}
+
+func TestReconcileConcurrently(t *testing.T) {
+	reconciler := NewReconciler()
+	reconciler.ReconcileDelay = time.Hour
+	driver := NewMockSchedulerDriver()
+
+	// the scheduler driver and the reconciliation loop may reconcile at the same time, only one of them should count
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reconciler.ExplicitReconcile([]string{"task"}, driver)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, driver.ReconcileTasksCount)
+	assert.Equal(t, 1, reconciler.Status().Tries)
+}
@@ -1,29 +1,69 @@
 package framework
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"github.com/golang/protobuf/proto"
 	mesos "github.com/mesos/mesos-go/mesosproto"
 	"github.com/mesos/mesos-go/scheduler"
 	"github.com/serejja/gonsumer-mesos/mesosfmt"
 	"github.com/yanzay/log"
+	"strings"
+	"sync"
 	"time"
 )
 
 type Scheduler interface {
 	Cluster() Cluster
+	Config() GonsumerFrameworkConfig
+	// GroupsJSON returns groups marshalled to JSON, so they are not modified by the scheduler while being read.
+	GroupsJSON() ([]byte, error)
+
+	AddGroup(group *Group) error
+	StartGroup(id string) error
+	StopGroup(id string) error
+	UpdateGroup(id string, update *GroupUpdate) error
+	ScaleGroup(id string, instances int) error
+	RemoveGroup(id string, force bool) error
+
+	Reconcile() error
+	ReconcileStatus() *ReconcileStatus
+
+	Status() *FrameworkStatus
 }
 
 type GonsumerScheduler struct {
+	config     GonsumerFrameworkConfig
 	driver     scheduler.SchedulerDriver
 	cluster    Cluster
 	storage    Storage
 	reconciler *Reconciler
+	// reconcileStop stops the currently running reconciliation loop, if any.
+	reconcileStop chan struct{}
+	// suppressed is set once offers are suppressed because every consumer is placed.
+	suppressed bool
+	// master is the leading master the framework is registered with, nil while disconnected.
+	master *mesos.MasterInfo
+	// lastError is the last error reported by the driver since the framework has registered.
+	lastError string
+	// deposed is closed once another scheduler instance has modified stored cluster state, so this one must not
+	// act upon its own state any longer.
+	deposed     chan struct{}
+	deposedOnce sync.Once
+	// saved is cluster state as it was loaded or saved last, so unchanged state is not written again.
+	saved []byte
+
+	// lock guards cluster modifications coming from both the scheduler driver and the HTTP server.
+	lock sync.Mutex
 }
 
-func NewScheduler(storage Storage) (*GonsumerScheduler, error) {
+func NewScheduler(config GonsumerFrameworkConfig, storage Storage) (*GonsumerScheduler, error) {
 	gonsumerScheduler := &GonsumerScheduler{
+		config:     config,
 		storage:    storage,
 		reconciler: NewReconciler(),
+		deposed:    make(chan struct{}),
 	}
 	gonsumerScheduler.reconciler.ReconcileDelay = 30 * time.Second
 
@@ -33,33 +73,113 @@ func NewScheduler(storage Storage) (*GonsumerScheduler, error) {
 
 func (s *GonsumerScheduler) Registered(driver scheduler.SchedulerDriver, id *mesos.FrameworkID, master *mesos.MasterInfo) {
 	log.Infof("[Registered] framework: %s master: %s:%d", id.GetValue(), master.GetHostname(), master.GetPort())
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
 	s.cluster.SetFrameworkID(id.GetValue())
 	s.SaveClusterState()
 
+	s.master = master
+	s.lastError = ""
 	s.driver = driver
-	s.reconciler.ImplicitReconcile(driver)
+	s.scheduleKillEscalations(time.Now())
+	s.startReconciliation(driver)
 }
 
 func (s *GonsumerScheduler) Reregistered(driver scheduler.SchedulerDriver, master *mesos.MasterInfo) {
 	log.Infof("[Reregistered] master: %s:%d", master.GetHostname(), master.GetPort())
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
+	if s.master != nil && s.master.GetId() != master.GetId() {
+		log.Infof("Leading master changed from %s to %s", masterAddress(s.master), masterAddress(master))
+	}
+	s.master = master
+	s.lastError = ""
 	s.driver = driver
-	s.reconciler.ImplicitReconcile(driver)
+	s.startReconciliation(driver)
 }
 
 func (s *GonsumerScheduler) Disconnected(scheduler.SchedulerDriver) {
 	log.Info("[Disconnected]")
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// the driver keeps detecting the leading master and reregisters once it is elected
+	if s.master != nil {
+		log.Infof("Disconnected from master %s, waiting for a new leading master", masterAddress(s.master))
+	}
+	s.master = nil
 }
 
 func (s *GonsumerScheduler) ResourceOffers(driver scheduler.SchedulerDriver, offers []*mesos.Offer) {
 	log.Debugf("[ResourceOffers] %s", mesosfmt.Offers(offers))
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
-	s.SaveClusterState() //TODO this should be only called when cluster state changed
+	s.restartFailedConsumers(time.Now())
+	s.replaceUnreachableConsumers(time.Now())
+	for _, offer := range offers {
+		declineReason := s.acceptOffer(driver, offer)
+		if declineReason != "" {
+			log.Debugf("Declined offer %s: %s", mesosfmt.ID(offer.GetId().GetValue()), declineReason)
+			_, err := driver.DeclineOffer(offer.GetId(), s.declineFilters())
+			if err != nil {
+				log.Errorf("Failed to decline offer %s: %s", mesosfmt.ID(offer.GetId().GetValue()), err)
+			}
+		}
+	}
+
+	s.suppressOffersIfIdle(driver)
+	s.SaveClusterState()
 }
 
 func (s *GonsumerScheduler) StatusUpdate(driver scheduler.SchedulerDriver, status *mesos.TaskStatus) {
 	log.Infof("[StatusUpdate] %s", mesosfmt.Status(status))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.reconciler.Update(status)
+	group, consumer := s.cluster.GetConsumerByTaskID(status.GetTaskId().GetValue())
+	if consumer == nil {
+		log.Warningf("Received status update for unknown task %s", status.GetTaskId().GetValue())
+		if isActive(status.GetState()) {
+			// e.g. a replaced unreachable task has come back, it must not run alongside its replacement
+			s.killTask(status.GetTaskId().GetValue())
+		}
+		return
+	}
+
+	previousState := consumer.State
+	failedBefore := consumer.awaitsRestart()
+	err := consumer.Update(status)
+	if err != nil {
+		log.Errorf("Failed to update consumer %s of group %s: %s", consumer.ID, group.ID, err)
+	}
+
+	if !failedBefore && consumer.awaitsRestart() {
+		consumer.Failed(s.config.RestartBackoff, time.Now())
+		log.Infof("Consumer %s of group %s failed %d time(s), restarting in %s", consumer.ID, group.ID,
+			consumer.Failures, consumer.RestartTime.Sub(time.Now()))
+		s.reviveOffers(driver, fmt.Sprintf("consumer %s of group %s failed", consumer.ID, group.ID))
+	} else if previousState != ConsumerStatePending && consumer.State == ConsumerStatePending {
+		s.reviveOffers(driver, fmt.Sprintf("consumer %s of group %s is pending", consumer.ID, group.ID))
+	} else if previousState != ConsumerStateUnreachable && consumer.State == ConsumerStateUnreachable {
+		log.Infof("Consumer %s of group %s is unreachable, replacing it in %s unless it comes back", consumer.ID, group.ID, group.UnreachableTimeout)
+		consumer.AddEvent("Task %s unreachable", consumer.TaskID)
+		// offers are needed to launch a replacement once the timeout expires
+		s.reviveOffers(driver, fmt.Sprintf("consumer %s of group %s is unreachable", consumer.ID, group.ID))
+	}
+
+	if previousState == ConsumerStateStopping && consumer.State == ConsumerStateStopped {
+		s.consumerStopped(group, consumer)
+	}
+
+	if consumer.Restarting && previousState == ConsumerStateStaging && consumer.State == ConsumerStateRunning {
+		// replacement of a restarted consumer is up, move on to the next one
+		consumer.Restarting = false
+		s.rollingRestart(group)
+	}
 
 	s.SaveClusterState()
 }
@@ -74,18 +194,113 @@ func (s *GonsumerScheduler) FrameworkMessage(driver scheduler.SchedulerDriver, e
 
 func (s *GonsumerScheduler) SlaveLost(driver scheduler.SchedulerDriver, slave *mesos.SlaveID) {
 	log.Infof("[SlaveLost] %s", slave.GetValue())
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, group := range s.allGroups() {
+		for _, consumer := range group.allConsumers() {
+			if consumer.TaskID != "" && consumer.SlaveID == slave.GetValue() {
+				s.consumerLost(group, consumer, fmt.Sprintf("slave %s (%s) lost", slave.GetValue(), consumer.Hostname), false)
+			}
+		}
+	}
+
+	s.SaveClusterState()
 }
 
 func (s *GonsumerScheduler) ExecutorLost(driver scheduler.SchedulerDriver, executor *mesos.ExecutorID, slave *mesos.SlaveID, status int) {
 	log.Infof("[ExecutorLost] executor: %s slave: %s status: %d", executor.GetValue(), slave.GetValue(), status)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// executors are launched with the same ID as their task
+	group, consumer := s.cluster.GetConsumerByTaskID(executor.GetValue())
+	if consumer == nil {
+		log.Warningf("Received executor lost for unknown executor %s", executor.GetValue())
+		return
+	}
+
+	// the executor is the consumer process, so its crash is a failure of the consumer
+	s.consumerLost(group, consumer, fmt.Sprintf("executor %s lost on slave %s with status %d", executor.GetValue(), slave.GetValue(), status), status != 0)
+	s.SaveClusterState()
+}
+
+// consumerLost clears the task assignment of a consumer whose task is gone along with its slave or executor
+// and queues it for relaunch. A failed consumer is relaunched according to the restart backoff, a lost one with
+// the next offer.
+func (s *GonsumerScheduler) consumerLost(group *Group, consumer *Consumer, reason string, failed bool) {
+	log.Infof("Consumer %s of group %s lost its task %s: %s", consumer.ID, group.ID, consumer.TaskID, reason)
+	consumer.AddEvent("Task %s lost: %s", consumer.TaskID, reason)
+	consumer.Reset()
+
+	if consumer.State == ConsumerStateStopping {
+		err := consumer.Transition(ConsumerStateStopped)
+		if err != nil {
+			log.Errorf("Failed to stop consumer %s of group %s: %s", consumer.ID, group.ID, err)
+			return
+		}
+
+		s.consumerStopped(group, consumer)
+		return
+	}
+
+	if consumer.State != ConsumerStateStaging && consumer.State != ConsumerStateRunning && consumer.State != ConsumerStateUnreachable {
+		// stopped and finished consumers are not relaunched, failed and lost ones are already awaiting restart
+		return
+	}
+
+	// a consumer killed for a restart is expected to exit
+	if failed && !consumer.Restarting {
+		err := consumer.Transition(ConsumerStateFailed)
+		if err != nil {
+			log.Errorf("Failed to mark consumer %s of group %s as failed: %s", consumer.ID, group.ID, err)
+			return
+		}
+
+		consumer.Failed(s.config.RestartBackoff, time.Now())
+		log.Infof("Consumer %s of group %s failed %d time(s), restarting in %s", consumer.ID, group.ID,
+			consumer.Failures, consumer.RestartTime.Sub(time.Now()))
+		s.reviveOffers(s.driver, fmt.Sprintf("consumer %s of group %s failed", consumer.ID, group.ID))
+		return
+	}
+
+	err := consumer.Transition(ConsumerStateLost)
+	if err != nil {
+		log.Errorf("Failed to mark consumer %s of group %s as lost: %s", consumer.ID, group.ID, err)
+		return
+	}
+
+	// losing a slave is not a failure of the consumer itself, so relaunch it with the next offer
+	consumer.RestartTime = time.Now()
+	s.reviveOffers(s.driver, fmt.Sprintf("consumer %s of group %s lost", consumer.ID, group.ID))
+
+	if consumer.Restarting {
+		// the relaunched consumer picks up the new configuration anyway, move on to the next one
+		consumer.Restart = false
+		consumer.Restarting = false
+		s.rollingRestart(group)
+	}
 }
 
 func (s *GonsumerScheduler) Error(driver scheduler.SchedulerDriver, err string) {
 	log.Errorf("[Error] %s", err)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if isAuthenticationError(err) {
+		log.Errorf("Failed to authenticate with Mesos master as principal %s, check --principal and --secret-file", s.config.Principal)
+	}
+	s.lastError = err
 }
 
 func (s *GonsumerScheduler) Shutdown(driver scheduler.SchedulerDriver) {
 	log.Info("Shutdown triggered, stopping driver")
+	s.lock.Lock()
+	if s.reconcileStop != nil {
+		close(s.reconcileStop)
+		s.reconcileStop = nil
+	}
+	s.lock.Unlock()
 
 	_, err := driver.Stop(false)
 	if err != nil {
@@ -93,14 +308,313 @@ func (s *GonsumerScheduler) Shutdown(driver scheduler.SchedulerDriver) {
 	}
 }
 
+// offerSuppressor is implemented by scheduler drivers able to suppress offers.
+type offerSuppressor interface {
+	SuppressOffers() (mesos.Status, error)
+}
+
+// hasPendingWork returns true if there are consumers to be launched now or once their backoff expires.
+func (s *GonsumerScheduler) hasPendingWork() bool {
+	for _, group := range s.cluster.GetGroups() {
+		for _, consumer := range group.Consumers {
+			if consumer.State == ConsumerStatePending || consumer.State == ConsumerStateUnreachable || consumer.awaitsRestart() {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// suppressOffersIfIdle stops offers from coming once every consumer is placed.
+// Drivers that cannot suppress offers get them declined for suppressedRefuseSeconds instead.
+// Must be called with lock held.
+func (s *GonsumerScheduler) suppressOffersIfIdle(driver scheduler.SchedulerDriver) {
+	if s.suppressed || s.hasPendingWork() {
+		return
+	}
+
+	s.suppressed = true
+	suppressor, ok := driver.(offerSuppressor)
+	if !ok {
+		log.Infof("All consumers are placed, declining offers for %.0f seconds", suppressedRefuseSeconds)
+		return
+	}
+
+	log.Info("All consumers are placed, suppressing offers")
+	_, err := suppressor.SuppressOffers()
+	if err != nil {
+		log.Errorf("Failed to suppress offers: %s", err)
+		s.suppressed = false
+	}
+}
+
+// reviveOffers asks for offers again, clearing filters of previously declined ones.
+// Must be called with lock held.
+func (s *GonsumerScheduler) reviveOffers(driver scheduler.SchedulerDriver, reason string) {
+	if driver == nil {
+		log.Warningf("Scheduler driver is not registered, cannot revive offers")
+		return
+	}
+
+	log.Infof("Reviving offers: %s", reason)
+	_, err := driver.ReviveOffers()
+	if err != nil {
+		log.Errorf("Failed to revive offers: %s", err)
+		return
+	}
+
+	s.suppressed = false
+}
+
+func (s *GonsumerScheduler) declineFilters() *mesos.Filters {
+	refuseSeconds := s.config.RefuseSeconds
+	if s.suppressed {
+		refuseSeconds = suppressedRefuseSeconds
+	}
+
+	return &mesos.Filters{RefuseSeconds: proto.Float64(refuseSeconds)}
+}
+
+// restartFailedConsumers moves failed and lost consumers whose backoff has expired back to pending.
+func (s *GonsumerScheduler) restartFailedConsumers(now time.Time) {
+	for _, group := range s.cluster.GetGroups() {
+		for _, consumer := range group.Consumers {
+			if !consumer.awaitsRestart() || consumer.RestartTime.After(now) {
+				continue
+			}
+
+			log.Infof("Restarting consumer %s of group %s after %d failure(s)", consumer.ID, group.ID, consumer.Failures)
+			consumer.Reset()
+			err := consumer.Transition(ConsumerStatePending)
+			if err != nil {
+				log.Errorf("Failed to restart consumer %s of group %s: %s", consumer.ID, group.ID, err)
+			}
+		}
+	}
+}
+
+// replaceUnreachableConsumers relaunches consumers that have been unreachable for longer than their group allows.
+// The unreachable task is killed and is killed again should it come back, so a group never runs duplicate consumers.
+func (s *GonsumerScheduler) replaceUnreachableConsumers(now time.Time) {
+	for _, group := range s.cluster.GetGroups() {
+		for _, consumer := range group.ConsumersWithState(ConsumerStateUnreachable) {
+			unreachableFor := now.Sub(consumer.UnreachableTime)
+			if unreachableFor < group.UnreachableTimeout {
+				continue
+			}
+
+			log.Infof("Consumer %s of group %s has been unreachable for %s, launching a replacement", consumer.ID, group.ID, unreachableFor)
+			consumer.AddEvent("Task %s unreachable for %s, replaced", consumer.TaskID, unreachableFor)
+			s.killTask(consumer.TaskID)
+			consumer.Reset()
+			consumer.UnreachableTime = time.Time{}
+			err := consumer.Transition(ConsumerStatePending)
+			if err != nil {
+				log.Errorf("Failed to replace consumer %s of group %s: %s", consumer.ID, group.ID, err)
+				continue
+			}
+
+			if consumer.Restarting {
+				// the replacement picks up the new configuration anyway, move on to the next one
+				consumer.Restart = false
+				consumer.Restarting = false
+				s.rollingRestart(group)
+			}
+		}
+	}
+}
+
+// acceptOffer places as many pending consumers onto a given offer as its resources allow and launches them.
+// Returns a decline reason if nothing was launched.
+func (s *GonsumerScheduler) acceptOffer(driver scheduler.SchedulerDriver, offer *mesos.Offer) string {
+	resources := newOfferResources(offer)
+	declineReasons := make([]string, 0)
+	tasks := make([]*mesos.TaskInfo, 0)
+	launched := make([]*Consumer, 0)
+
+	for _, group := range s.cluster.GetGroups() {
+		for _, consumer := range group.PendingConsumers() {
+			declineReason := resources.matches(group)
+			if declineReason != "" {
+				declineReasons = append(declineReasons, fmt.Sprintf("group %s: %s", group.ID, declineReason))
+				break
+			}
+
+			declineReason = constraintsMatch(group, offer)
+			if declineReason != "" {
+				declineReasons = append(declineReasons, fmt.Sprintf("group %s: %s", group.ID, declineReason))
+				break
+			}
+
+			taskResources, ports := resources.consume(group)
+			task, err := newTaskInfo(s.config, group, consumer, offer, taskResources, ports)
+			if err != nil {
+				log.Errorf("Failed to create task for consumer %s of group %s: %s", consumer.ID, group.ID, err)
+				break
+			}
+
+			err = consumer.Launch(task.GetTaskId().GetValue(), offer)
+			if err != nil {
+				log.Errorf("Failed to launch consumer %s of group %s: %s", consumer.ID, group.ID, err)
+				break
+			}
+			consumer.Ports = ports
+			tasks = append(tasks, task)
+			launched = append(launched, consumer)
+		}
+	}
+
+	if len(tasks) == 0 {
+		if len(declineReasons) == 0 {
+			return "no pending consumers"
+		}
+
+		return strings.Join(declineReasons, ", ")
+	}
+
+	log.Infof("Launching %d task(s) on offer %s", len(tasks), mesosfmt.Offer(offer))
+	_, err := driver.LaunchTasks([]*mesos.OfferID{offer.GetId()}, tasks, nil)
+	if err != nil {
+		for _, consumer := range launched {
+			consumer.Transition(ConsumerStatePending)
+			consumer.Reset()
+		}
+
+		return fmt.Sprintf("failed to launch tasks: %s", err)
+	}
+
+	return ""
+}
+
+func (s *GonsumerScheduler) Reconcile() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.driver == nil {
+		return ErrNotRegistered
+	}
+
+	s.startReconciliation(s.driver)
+	return nil
+}
+
+func (s *GonsumerScheduler) ReconcileStatus() *ReconcileStatus {
+	return s.reconciler.Status()
+}
+
+func (s *GonsumerScheduler) Status() *FrameworkStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return &FrameworkStatus{
+		FrameworkID:   s.cluster.GetFrameworkID(),
+		Registered:    s.master != nil,
+		Master:        s.config.Master,
+		LeadingMaster: masterAddress(s.master),
+		Principal:     s.config.Principal,
+		Error:         s.lastError,
+	}
+}
+
+// startReconciliation explicitly reconciles all known tasks and keeps retrying on a timer until every task has answered.
+// Implicit reconciliation follows to find out about tasks the framework does not know of.
+// Must be called with lock held.
+func (s *GonsumerScheduler) startReconciliation(driver scheduler.SchedulerDriver) {
+	if s.reconcileStop != nil {
+		close(s.reconcileStop)
+		s.reconcileStop = nil
+	}
+	s.reconciler.Reset()
+
+	taskIDs := make([]string, 0)
+	for _, group := range s.allGroups() {
+		for _, consumer := range group.allConsumers() {
+			if consumer.TaskID != "" {
+				taskIDs = append(taskIDs, consumer.TaskID)
+			}
+		}
+	}
+
+	if len(taskIDs) == 0 {
+		err := s.reconciler.ImplicitReconcile(driver)
+		if err != nil {
+			log.Errorf("Implicit reconciliation failed: %s", err)
+		}
+		return
+	}
+
+	log.Infof("Starting explicit reconciliation of %d task(s)", len(taskIDs))
+	err := s.reconciler.ExplicitReconcile(taskIDs, driver)
+	if err != nil {
+		log.Errorf("Explicit reconciliation failed: %s", err)
+	}
+
+	s.reconcileStop = make(chan struct{})
+	go s.reconcileLoop(driver, s.reconcileStop)
+}
+
+func (s *GonsumerScheduler) reconcileLoop(driver scheduler.SchedulerDriver, stop chan struct{}) {
+	for {
+		// wait a full delay after the previous attempt so it is not throttled by the reconciler
+		select {
+		case <-stop:
+			return
+		case <-time.After(s.reconciler.ReconcileDelay):
+			if s.reconcileTick(driver) {
+				return
+			}
+		}
+	}
+}
+
+// reconcileTick retries explicit reconciliation of tasks that have not answered yet.
+// Returns true and runs implicit reconciliation once all tasks have answered.
+func (s *GonsumerScheduler) reconcileTick(driver scheduler.SchedulerDriver) bool {
+	if s.reconciler.Done() {
+		log.Info("Explicit reconciliation finished")
+		err := s.reconciler.ImplicitReconcile(driver)
+		if err != nil {
+			log.Errorf("Implicit reconciliation failed: %s", err)
+		}
+		return true
+	}
+
+	err := s.reconciler.ExplicitReconcile(nil, driver)
+	if err != nil {
+		log.Errorf("Explicit reconciliation failed: %s", err)
+	}
+	return false
+}
+
+// allGroups returns groups of the cluster along with removed groups whose consumers are still stopping.
+func (s *GonsumerScheduler) allGroups() []*Group {
+	return append(s.cluster.GetGroups(), s.cluster.GetStoppingGroups()...)
+}
+
 func (s *GonsumerScheduler) Cluster() Cluster {
 	return s.cluster
 }
 
+func (s *GonsumerScheduler) GroupsJSON() ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return json.Marshal(s.cluster.GetGroups())
+}
+
+func (s *GonsumerScheduler) Config() GonsumerFrameworkConfig {
+	return s.config
+}
+
 func (s *GonsumerScheduler) LoadClusterState() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
 	rawCluster, err := s.storage.Load()
 	if err == ErrStorageUninitialized {
 		s.cluster = NewGonsumerCluster()
+		s.saved = nil
 		return nil
 	}
 
@@ -108,14 +622,43 @@ func (s *GonsumerScheduler) LoadClusterState() error {
 		return err
 	}
 
-	return json.Unmarshal(rawCluster, &s.cluster)
+	cluster := NewGonsumerCluster()
+	err = json.Unmarshal(rawCluster, cluster)
+	if err != nil {
+		return err
+	}
+
+	s.cluster = cluster
+	s.saved = rawCluster
+	return nil
 }
 
+// SaveClusterState writes cluster state to the storage unless it has not changed since it was loaded or saved last.
 func (s *GonsumerScheduler) SaveClusterState() error {
 	clusterJSON, err := json.Marshal(s.cluster)
 	if err != nil {
 		return err
 	}
 
-	return s.storage.Save(clusterJSON)
+	if s.saved != nil && bytes.Equal(clusterJSON, s.saved) {
+		return nil
+	}
+
+	err = s.storage.Save(clusterJSON)
+	if err != nil {
+		log.Errorf("Failed to save cluster state to %s: %s", s.storage, err)
+	} else {
+		s.saved = clusterJSON
+	}
+
+	if err == ErrStorageConflict {
+		s.deposedOnce.Do(func() { close(s.deposed) })
+	}
+
+	return err
+}
+
+// Deposed is closed once cluster state fails to be saved because another scheduler instance has modified it.
+func (s *GonsumerScheduler) Deposed() <-chan struct{} {
+	return s.deposed
 }
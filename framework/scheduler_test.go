@@ -0,0 +1,717 @@
+package framework
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	util "github.com/mesos/mesos-go/mesosutil"
+	"github.com/mesos/mesos-go/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestScheduler(t *testing.T) *GonsumerScheduler {
+	scheduler, err := NewScheduler(NewConfig(), NewMockStorage())
+	require.Nil(t, err)
+
+	return scheduler
+}
+
+func newTestGroup(id string) *Group {
+	group := NewGroup(id)
+	group.Cpus = DefaultConsumerCpus
+	group.Mem = DefaultConsumerMem
+
+	return group
+}
+
+func newTestOffer(id string, cpus float64, mem float64) *mesos.Offer {
+	offer := util.NewOffer(util.NewOfferID(id), util.NewFrameworkID("framework"), util.NewSlaveID("slave-"+id), "host-"+id)
+	offer.Resources = []*mesos.Resource{
+		util.NewScalarResource("cpus", cpus),
+		util.NewScalarResource("mem", mem),
+	}
+
+	return offer
+}
+
+func TestResourceOffersNoGroups(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	assert.Equal(t, 0, driver.LaunchTasksCount)
+	assert.Equal(t, 1, driver.DeclineOfferCount)
+}
+
+func TestResourceOffersLaunchesPendingConsumers(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+
+	group := newTestGroup("foo")
+	group.Consumers = append(group.Consumers, NewConsumer("1"))
+	scheduler.Cluster().AddGroup(group)
+
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	assert.Equal(t, 1, driver.LaunchTasksCount)
+	assert.Equal(t, 0, driver.DeclineOfferCount)
+	require.Len(t, driver.LaunchedTasks, 2)
+	assert.Empty(t, group.PendingConsumers())
+
+	task := driver.LaunchedTasks[0]
+	assert.Equal(t, group.Consumers[0].TaskID, task.GetTaskId().GetValue())
+	assert.Equal(t, "slave-1", task.GetSlaveId().GetValue())
+	assert.Equal(t, executorCommand, task.GetExecutor().GetCommand().GetValue())
+	uris := task.GetExecutor().GetCommand().GetUris()
+	require.Len(t, uris, 1)
+	assert.True(t, uris[0].GetExecutable())
+	assert.False(t, uris[0].GetExtract())
+
+	// executors get consumer configuration only, not state of the group's consumers
+	var data map[string]interface{}
+	require.Nil(t, json.Unmarshal(task.GetData(), &data))
+	assert.Equal(t, "foo", data["group_id"])
+	assert.NotContains(t, data, "consumers")
+
+	// all consumers are running so subsequent offers should be declined
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("2", 4, 4096)})
+	assert.Equal(t, 1, driver.LaunchTasksCount)
+	assert.Equal(t, 1, driver.DeclineOfferCount)
+}
+
+func TestResourceOffersInsufficientResources(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+
+	group := newTestGroup("foo")
+	group.Consumers = append(group.Consumers, NewConsumer("1"))
+	scheduler.Cluster().AddGroup(group)
+
+	// not enough cpus for any consumer
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 0.1, 4096)})
+	assert.Equal(t, 0, driver.LaunchTasksCount)
+	assert.Equal(t, 1, driver.DeclineOfferCount)
+	assert.Len(t, group.PendingConsumers(), 2)
+
+	// enough resources for just one consumer
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("2", DefaultConsumerCpus, DefaultConsumerMem)})
+	assert.Equal(t, 1, driver.LaunchTasksCount)
+	assert.Len(t, driver.LaunchedTasks, 1)
+	assert.Len(t, group.PendingConsumers(), 1)
+}
+
+func TestResourceOffersGroupResources(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+
+	group := newTestGroup("foo")
+	group.Cpus = 2
+	group.Mem = 1024
+	group.Disk = 100
+	scheduler.Cluster().AddGroup(group)
+
+	// offer has no disk
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	assert.Equal(t, 0, driver.LaunchTasksCount)
+	assert.Equal(t, 1, driver.DeclineOfferCount)
+
+	offer := newTestOffer("2", 4, 4096)
+	offer.Resources = append(offer.Resources, util.NewScalarResource("disk", 1000))
+	scheduler.ResourceOffers(driver, []*mesos.Offer{offer})
+	assert.Equal(t, 1, driver.LaunchTasksCount)
+	require.Len(t, driver.LaunchedTasks, 1)
+
+	resources := driver.LaunchedTasks[0].GetResources()
+	require.Len(t, resources, 3)
+	assert.Equal(t, 2.0, resources[0].GetScalar().GetValue())
+	assert.Equal(t, 1024.0, resources[1].GetScalar().GetValue())
+	assert.Equal(t, 100.0, resources[2].GetScalar().GetValue())
+}
+
+func TestResourceOffersConstraints(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+
+	group := newTestGroup("foo")
+	group.Scale(2)
+	var err error
+	group.Constraints, err = ParseConstraints("hostname=unique")
+	require.Nil(t, err)
+	scheduler.Cluster().AddGroup(group)
+
+	// only one consumer fits a single host even though there are enough resources for both
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	assert.Equal(t, 1, driver.LaunchTasksCount)
+	assert.Len(t, driver.LaunchedTasks, 1)
+	assert.Len(t, group.PendingConsumers(), 1)
+
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	assert.Equal(t, 1, driver.LaunchTasksCount)
+	assert.Equal(t, 1, driver.DeclineOfferCount)
+
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("2", 4, 4096)})
+	assert.Equal(t, 2, driver.LaunchTasksCount)
+	assert.Empty(t, group.PendingConsumers())
+}
+
+func TestResourceOffersSuppressRevive(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+	scheduler.Registered(driver, util.NewFrameworkID("framework"), util.NewMasterInfo("master", 0, 5050))
+
+	// there are no groups yet, so the offer is declined and further offers are suppressed
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 0.1, 4096)})
+	require.Len(t, driver.DeclineFilters, 1)
+	assert.Equal(t, DefaultRefuseSeconds, driver.DeclineFilters[0].GetRefuseSeconds())
+	assert.Equal(t, 1, driver.SuppressOffersCount)
+
+	group := newTestGroup("foo")
+	require.Nil(t, scheduler.AddGroup(group))
+	assert.Equal(t, 1, driver.ReviveOffersCount)
+
+	// insufficient offers should be declined with configured filter
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("2", 0.1, 4096)})
+	require.Len(t, driver.DeclineFilters, 2)
+	assert.Equal(t, DefaultRefuseSeconds, driver.DeclineFilters[1].GetRefuseSeconds())
+	assert.Equal(t, 1, driver.SuppressOffersCount)
+
+	// every consumer is placed so offers should be suppressed
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("3", 4, 4096)})
+	assert.Equal(t, 2, driver.SuppressOffersCount)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("4", 4, 4096)})
+	assert.Equal(t, 2, driver.SuppressOffersCount)
+
+	require.Nil(t, scheduler.ScaleGroup("foo", 2))
+	assert.Equal(t, 2, driver.ReviveOffersCount)
+
+	// scaling down should not revive offers
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("5", 4, 4096)})
+	assert.Equal(t, 3, driver.SuppressOffersCount)
+	require.Nil(t, scheduler.ScaleGroup("foo", 1))
+	assert.Equal(t, 2, driver.ReviveOffersCount)
+
+	consumer := group.Consumers[0]
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(consumer.TaskID), mesos.TaskState_TASK_FAILED))
+	assert.Equal(t, 3, driver.ReviveOffersCount)
+	assert.False(t, scheduler.suppressed)
+}
+
+// noSuppressDriver hides SuppressOffers of the mock driver like older scheduler drivers.
+type noSuppressDriver struct {
+	scheduler.SchedulerDriver
+}
+
+func TestResourceOffersSuppressUnsupported(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+
+	scheduler.ResourceOffers(noSuppressDriver{driver}, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	scheduler.ResourceOffers(noSuppressDriver{driver}, []*mesos.Offer{newTestOffer("2", 4, 4096)})
+	assert.Equal(t, 0, driver.SuppressOffersCount)
+	require.Len(t, driver.DeclineFilters, 2)
+	assert.Equal(t, DefaultRefuseSeconds, driver.DeclineFilters[0].GetRefuseSeconds())
+	assert.Equal(t, suppressedRefuseSeconds, driver.DeclineFilters[1].GetRefuseSeconds())
+}
+
+func TestResourceOffersPorts(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+
+	group := newTestGroup("foo")
+	group.Ports = 2
+	group.Scale(2)
+	scheduler.Cluster().AddGroup(group)
+
+	// offer without ports should be declined
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	assert.Equal(t, 0, driver.LaunchTasksCount)
+	assert.Equal(t, 1, driver.DeclineOfferCount)
+
+	// three ports are enough for just one consumer
+	offer := newTestOffer("2", 4, 4096)
+	offer.Resources = append(offer.Resources, util.NewRangesResource("ports", []*mesos.Value_Range{
+		util.NewValueRange(31000, 31000),
+		util.NewValueRange(31005, 31006),
+	}))
+	scheduler.ResourceOffers(driver, []*mesos.Offer{offer})
+	assert.Equal(t, 1, driver.LaunchTasksCount)
+	require.Len(t, driver.LaunchedTasks, 1)
+	assert.Len(t, group.PendingConsumers(), 1)
+
+	consumer := group.ConsumersWithState(ConsumerStateStaging)[0]
+	assert.Equal(t, []uint64{31000, 31005}, consumer.Ports)
+
+	task := driver.LaunchedTasks[0]
+	ports := task.GetResources()[2]
+	assert.Equal(t, "ports", ports.GetName())
+	require.Len(t, ports.GetRanges().GetRange(), 2)
+	assert.Equal(t, uint64(31000), ports.GetRanges().GetRange()[0].GetBegin())
+	assert.Equal(t, uint64(31005), ports.GetRanges().GetRange()[1].GetEnd())
+
+	env := make(map[string]string)
+	for _, variable := range task.GetExecutor().GetCommand().GetEnvironment().GetVariables() {
+		env[variable.GetName()] = variable.GetValue()
+	}
+	assert.Equal(t, map[string]string{"PORT0": "31000", "PORT1": "31005", "PORTS": "31000,31005"}, env)
+
+	// ports are released along with the task
+	consumer.Reset()
+	assert.Empty(t, consumer.Ports)
+}
+
+func TestResourceOffersReservedResources(t *testing.T) {
+	config := NewConfig()
+	config.FrameworkRole = "kafka"
+	scheduler, err := NewScheduler(config, NewMockStorage())
+	require.Nil(t, err)
+	driver := NewMockSchedulerDriver()
+
+	group := newTestGroup("foo")
+	group.Cpus = 2
+	group.Mem = 512
+	group.Ports = 1
+	scheduler.Cluster().AddGroup(group)
+
+	reserved := func(resource *mesos.Resource) *mesos.Resource {
+		resource.Role = proto.String("kafka")
+		return resource
+	}
+	offer := newTestOffer("1", 4, 4096)
+	offer.Resources = append(offer.Resources,
+		reserved(util.NewScalarResource("cpus", 1)),
+		reserved(util.NewScalarResource("mem", 1024)),
+		reserved(util.NewRangesResource("ports", []*mesos.Value_Range{util.NewValueRange(31000, 31000)})),
+	)
+
+	// reserved resources are used first, the rest is taken from unreserved ones
+	scheduler.ResourceOffers(driver, []*mesos.Offer{offer})
+	require.Len(t, driver.LaunchedTasks, 1)
+	resources := make([]string, 0)
+	for _, resource := range driver.LaunchedTasks[0].GetResources() {
+		resources = append(resources, fmt.Sprintf("%s(%s):%s", resource.GetName(), resource.GetRole(), resourceValue(resource)))
+	}
+	assert.Equal(t, []string{"cpus(kafka):1", "cpus(*):1", "mem(kafka):512", "ports(kafka):31000"}, resources)
+}
+
+func resourceValue(resource *mesos.Resource) string {
+	if resource.GetRanges() != nil {
+		ranges := make([]string, 0)
+		for _, valueRange := range resource.GetRanges().GetRange() {
+			ranges = append(ranges, fmt.Sprintf("%d", valueRange.GetBegin()))
+		}
+		return strings.Join(ranges, ",")
+	}
+
+	return fmt.Sprintf("%g", resource.GetScalar().GetValue())
+}
+
+func TestResourceOffersLaunchError(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+	driver.LaunchTasksError = errors.New("boom!")
+
+	group := newTestGroup("foo")
+	scheduler.Cluster().AddGroup(group)
+
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	assert.Equal(t, 1, driver.LaunchTasksCount)
+	assert.Equal(t, 1, driver.DeclineOfferCount)
+	assert.Len(t, group.PendingConsumers(), 1)
+}
+
+func TestStatusUpdate(t *testing.T) {
+	storage := NewMockStorage()
+	scheduler, err := NewScheduler(NewConfig(), storage)
+	require.Nil(t, err)
+	driver := NewMockSchedulerDriver()
+
+	group := newTestGroup("foo")
+	scheduler.Cluster().AddGroup(group)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	consumer := group.Consumers[0]
+	require.Equal(t, ConsumerStateStaging, consumer.State)
+	saves := storage.SaveCount
+
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(consumer.TaskID), mesos.TaskState_TASK_RUNNING))
+	assert.Equal(t, ConsumerStateRunning, consumer.State)
+	assert.Equal(t, saves+1, storage.SaveCount)
+
+	// unknown tasks should not affect any consumer
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID("unknown"), mesos.TaskState_TASK_LOST))
+	assert.Equal(t, ConsumerStateRunning, consumer.State)
+
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(consumer.TaskID), mesos.TaskState_TASK_LOST))
+	assert.Equal(t, ConsumerStateLost, consumer.State)
+	assert.Equal(t, "slave-1", consumer.SlaveID)
+	assert.Equal(t, "host-1", consumer.Hostname)
+}
+
+func TestStatusUpdateRestartsFailedConsumer(t *testing.T) {
+	storage := NewMockStorage()
+	config := NewConfig()
+	config.RestartBackoff = RestartBackoff{Delay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour}
+	scheduler, err := NewScheduler(config, storage)
+	require.Nil(t, err)
+	driver := NewMockSchedulerDriver()
+
+	group := newTestGroup("foo")
+	scheduler.Cluster().AddGroup(group)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	consumer := group.Consumers[0]
+
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(consumer.TaskID), mesos.TaskState_TASK_RUNNING))
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(consumer.TaskID), mesos.TaskState_TASK_LOST))
+	assert.Equal(t, ConsumerStateLost, consumer.State)
+	assert.Equal(t, 1, consumer.Failures)
+	restartTime := consumer.RestartTime
+	assert.True(t, restartTime.After(time.Now()))
+
+	// further updates of the same failed task should not count as another failure
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(consumer.TaskID), mesos.TaskState_TASK_FAILED))
+	assert.Equal(t, ConsumerStateFailed, consumer.State)
+	assert.Equal(t, 1, consumer.Failures)
+
+	// backoff state should survive a failover
+	failedOver, err := NewScheduler(config, storage)
+	require.Nil(t, err)
+	loadedConsumer := failedOver.Cluster().GetGroup("foo").Consumers[0]
+	assert.Equal(t, 1, loadedConsumer.Failures)
+	assert.True(t, restartTime.Equal(loadedConsumer.RestartTime))
+
+	// consumer should not be relaunched until its backoff expires
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("2", 4, 4096)})
+	assert.Equal(t, 1, driver.LaunchTasksCount)
+	assert.Equal(t, ConsumerStateFailed, consumer.State)
+
+	consumer.RestartTime = time.Now().Add(-time.Second)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("3", 4, 4096)})
+	assert.Equal(t, 2, driver.LaunchTasksCount)
+	assert.Equal(t, ConsumerStateStaging, consumer.State)
+	assert.Equal(t, 1, consumer.Failures)
+}
+
+func TestSlaveLost(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+
+	group := newTestGroup("foo")
+	group.Scale(3)
+	scheduler.Cluster().AddGroup(group)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", DefaultConsumerCpus*2, DefaultConsumerMem*2)})
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("2", 4, 4096)})
+	require.Empty(t, group.PendingConsumers())
+
+	scheduler.SlaveLost(driver, util.NewSlaveID("slave-1"))
+	lost := group.ConsumersWithState(ConsumerStateLost)
+	require.Len(t, lost, 2)
+	for _, consumer := range lost {
+		assert.Empty(t, consumer.TaskID)
+		assert.Empty(t, consumer.SlaveID)
+		require.Len(t, consumer.Events, 1)
+		assert.Contains(t, consumer.Events[0].Message, "slave slave-1 (host-1) lost")
+		assert.Equal(t, 0, consumer.Failures)
+	}
+	assert.Len(t, group.ConsumersWithState(ConsumerStateStaging), 1)
+
+	// lost consumers should be relaunched with the next offer
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("3", 4, 4096)})
+	assert.Equal(t, 3, driver.LaunchTasksCount)
+	assert.Len(t, group.ConsumersWithState(ConsumerStateStaging), 3)
+	assert.Equal(t, "slave-3", lost[0].SlaveID)
+}
+
+func TestExecutorLost(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+
+	group := newTestGroup("foo")
+	scheduler.Cluster().AddGroup(group)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	consumer := group.Consumers[0]
+	taskID := consumer.TaskID
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(taskID), mesos.TaskState_TASK_RUNNING))
+
+	// unknown executors should not affect any consumer
+	scheduler.ExecutorLost(driver, util.NewExecutorID("unknown"), util.NewSlaveID("slave-1"), 1)
+	assert.Equal(t, ConsumerStateRunning, consumer.State)
+
+	// an executor exiting with an error is a crash of the consumer, which is relaunched after a backoff
+	scheduler.ExecutorLost(driver, util.NewExecutorID(taskID), util.NewSlaveID("slave-1"), 1)
+	assert.Equal(t, ConsumerStateFailed, consumer.State)
+	assert.Empty(t, consumer.TaskID)
+	assert.Equal(t, 1, consumer.Failures)
+	assert.True(t, consumer.RestartTime.After(time.Now()))
+	require.Len(t, consumer.Events, 1)
+	assert.Contains(t, consumer.Events[0].Message, "executor "+taskID+" lost")
+
+	// status updates of the lost task should be ignored afterwards
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(taskID), mesos.TaskState_TASK_FAILED))
+	assert.Equal(t, ConsumerStateFailed, consumer.State)
+	assert.Equal(t, 1, consumer.Failures)
+
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("2", 4, 4096)})
+	assert.Equal(t, 1, driver.LaunchTasksCount)
+	assert.Equal(t, ConsumerStateFailed, consumer.State)
+
+	// an executor exiting cleanly is not a failure, the consumer is relaunched with the next offer
+	consumer.RestartTime = time.Now()
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("3", 4, 4096)})
+	require.Equal(t, ConsumerStateStaging, consumer.State)
+	scheduler.ExecutorLost(driver, util.NewExecutorID(consumer.TaskID), util.NewSlaveID("slave-3"), 0)
+	assert.Equal(t, ConsumerStateLost, consumer.State)
+	assert.Equal(t, 1, consumer.Failures)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("4", 4, 4096)})
+	assert.Equal(t, ConsumerStateStaging, consumer.State)
+}
+
+func TestStatusUpdateUnreachable(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+	scheduler.Registered(driver, util.NewFrameworkID("framework"), util.NewMasterInfo("master", 0, 5050))
+
+	group := newTestGroup("foo")
+	group.UnreachableTimeout = time.Minute
+	scheduler.Cluster().AddGroup(group)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	consumer := group.Consumers[0]
+	taskID := consumer.TaskID
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(taskID), mesos.TaskState_TASK_RUNNING))
+
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(taskID), mesos.TaskState_TASK_UNREACHABLE))
+	assert.Equal(t, ConsumerStateUnreachable, consumer.State)
+	assert.False(t, consumer.UnreachableTime.IsZero())
+	assert.Equal(t, 0, consumer.Failures)
+	require.Len(t, consumer.Events, 1)
+	assert.Contains(t, consumer.Events[0].Message, "unreachable")
+
+	// consumer should not be replaced until its group timeout expires
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("2", 4, 4096)})
+	assert.Equal(t, 1, driver.LaunchTasksCount)
+	assert.Equal(t, ConsumerStateUnreachable, consumer.State)
+
+	// task coming back before the timeout should just keep running
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(taskID), mesos.TaskState_TASK_RUNNING))
+	assert.Equal(t, ConsumerStateRunning, consumer.State)
+	assert.True(t, consumer.UnreachableTime.IsZero())
+	assert.Equal(t, 0, driver.KillTaskCount)
+
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(taskID), mesos.TaskState_TASK_UNREACHABLE))
+	consumer.UnreachableTime = time.Now().Add(-2 * time.Minute)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("3", 4, 4096)})
+	assert.Equal(t, 1, driver.KillTaskCount)
+	assert.Equal(t, 2, driver.LaunchTasksCount)
+	assert.Equal(t, ConsumerStateStaging, consumer.State)
+	assert.NotEqual(t, taskID, consumer.TaskID)
+	assert.Equal(t, "slave-3", consumer.SlaveID)
+	assert.Contains(t, consumer.Events[len(consumer.Events)-1].Message, "replaced")
+
+	// replaced task should be killed once its agent reconnects
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(taskID), mesos.TaskState_TASK_RUNNING))
+	assert.Equal(t, 2, driver.KillTaskCount)
+	assert.Equal(t, ConsumerStateStaging, consumer.State)
+}
+
+func TestStatusUpdateGone(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+
+	group := newTestGroup("foo")
+	scheduler.Cluster().AddGroup(group)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	consumer := group.Consumers[0]
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(consumer.TaskID), mesos.TaskState_TASK_RUNNING))
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(consumer.TaskID), mesos.TaskState_TASK_UNREACHABLE))
+
+	// agent that is gone for good should not hold the consumer until the timeout
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(consumer.TaskID), mesos.TaskState_TASK_GONE))
+	assert.Equal(t, ConsumerStateLost, consumer.State)
+	assert.True(t, consumer.UnreachableTime.IsZero())
+	assert.Equal(t, 1, consumer.Failures)
+}
+
+func TestRegisteredReconcile(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+	master := util.NewMasterInfo("master", 0, 5050)
+
+	// nothing to reconcile explicitly without tasks
+	scheduler.Registered(driver, util.NewFrameworkID("framework"), master)
+	assert.Equal(t, 1, driver.ReconcileTasksCount)
+	assert.True(t, scheduler.reconciler.Done())
+	assert.Nil(t, scheduler.reconcileStop)
+
+	group := newTestGroup("foo")
+	group.Scale(2)
+	scheduler.Cluster().AddGroup(group)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+
+	scheduler.reconciler.ReconcileMaxTries = 2
+	scheduler.Reregistered(driver, master)
+	defer scheduler.Shutdown(driver)
+	assert.Equal(t, 2, driver.ReconcileTasksCount)
+	assert.Len(t, scheduler.ReconcileStatus().PendingTasks, 2)
+	assert.NotNil(t, scheduler.reconcileStop)
+
+	// task status stream should feed the reconciler
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(group.Consumers[0].TaskID), mesos.TaskState_TASK_RUNNING))
+	assert.Equal(t, []string{group.Consumers[1].TaskID}, scheduler.ReconcileStatus().PendingTasks)
+
+	// reconciliation is retried until every task answers, pretend the reconcile delay has passed
+	scheduler.reconciler.reconcileTime = time.Unix(0, 0)
+	assert.False(t, scheduler.reconcileTick(driver))
+	assert.Equal(t, 3, driver.ReconcileTasksCount)
+
+	scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(group.Consumers[1].TaskID), mesos.TaskState_TASK_RUNNING))
+	scheduler.reconciler.reconcileTime = time.Unix(0, 0)
+	assert.True(t, scheduler.reconcileTick(driver))
+	assert.Equal(t, 4, driver.ReconcileTasksCount) // final implicit reconciliation
+	assert.Equal(t, 0, driver.KillTaskCount)
+}
+
+func TestMasterFailover(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+	scheduler.config.Master = "zk://zk1:2181,zk2:2181/mesos"
+
+	status := scheduler.Status()
+	assert.False(t, status.Registered)
+	assert.Equal(t, "", status.LeadingMaster)
+	assert.Equal(t, "zk://zk1:2181,zk2:2181/mesos", status.Master)
+
+	// 0x0100007f is 127.0.0.1 in network byte order
+	scheduler.Registered(driver, util.NewFrameworkID("framework"), util.NewMasterInfo("master1", 0x0100007f, 5050))
+	defer scheduler.Shutdown(driver)
+	status = scheduler.Status()
+	assert.True(t, status.Registered)
+	assert.Equal(t, "framework", status.FrameworkID)
+	assert.Equal(t, "127.0.0.1:5050", status.LeadingMaster)
+
+	scheduler.Disconnected(driver)
+	status = scheduler.Status()
+	assert.False(t, status.Registered)
+	assert.Equal(t, "", status.LeadingMaster)
+
+	master := util.NewMasterInfo("master2", 0, 5051)
+	master.Hostname = proto.String("master2.example.com")
+	scheduler.Reregistered(driver, master)
+	status = scheduler.Status()
+	assert.True(t, status.Registered)
+	assert.Equal(t, "master2.example.com:5051", status.LeadingMaster)
+}
+
+func TestAuthenticationError(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	driver := NewMockSchedulerDriver()
+	scheduler.config.Principal = "gonsumer"
+
+	scheduler.Error(driver, "authentication failed")
+	status := scheduler.Status()
+	assert.False(t, status.Registered)
+	assert.Equal(t, "gonsumer", status.Principal)
+	assert.Equal(t, "authentication failed", status.Error)
+
+	// error is cleared once the framework registers
+	scheduler.Registered(driver, util.NewFrameworkID("framework"), util.NewMasterInfo("master", 0, 5050))
+	defer scheduler.Shutdown(driver)
+	assert.Equal(t, "", scheduler.Status().Error)
+}
+
+func TestClusterStatePersistence(t *testing.T) {
+	storage := NewMockStorage()
+	scheduler, err := NewScheduler(NewConfig(), storage)
+	require.Nil(t, err)
+
+	group := newTestGroup("foo")
+	group.Disk = 100
+	scheduler.Cluster().SetFrameworkID("framework")
+	scheduler.Cluster().AddGroup(group)
+	require.Nil(t, scheduler.SaveClusterState())
+
+	scheduler, err = NewScheduler(NewConfig(), storage)
+	require.Nil(t, err)
+	assert.Equal(t, "framework", scheduler.Cluster().GetFrameworkID())
+
+	loadedGroup := scheduler.Cluster().GetGroup("foo")
+	require.NotNil(t, loadedGroup)
+	assert.Equal(t, DefaultConsumerCpus, loadedGroup.Cpus)
+	assert.Equal(t, float64(DefaultConsumerMem), loadedGroup.Mem)
+	assert.Equal(t, 100.0, loadedGroup.Disk)
+	assert.Len(t, loadedGroup.Consumers, 1)
+}
+
+func TestSaveClusterStateConflict(t *testing.T) {
+	storage := NewMockStorage()
+	scheduler, err := NewScheduler(NewConfig(), storage)
+	require.Nil(t, err)
+
+	storage.SaveError = errors.New("boom")
+	assert.Equal(t, storage.SaveError, scheduler.SaveClusterState())
+	select {
+	case <-scheduler.Deposed():
+		t.Fatal("Scheduler should not step down on errors other than a conflict")
+	default:
+	}
+
+	// another instance has taken over, so this one should not act any longer
+	storage.SaveError = ErrStorageConflict
+	assert.Equal(t, ErrStorageConflict, scheduler.SaveClusterState())
+	assert.Equal(t, ErrStorageConflict, scheduler.SaveClusterState())
+	select {
+	case <-scheduler.Deposed():
+	default:
+		t.Fatal("Scheduler should step down on a conflict")
+	}
+}
+
+func TestResourceOffersSavesChangedState(t *testing.T) {
+	storage := NewMockStorage()
+	scheduler, err := NewScheduler(NewConfig(), storage)
+	require.Nil(t, err)
+	driver := NewMockSchedulerDriver()
+	scheduler.Cluster().AddGroup(newTestGroup("foo"))
+
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	require.Equal(t, 1, driver.LaunchTasksCount)
+	saves := storage.SaveCount
+	assert.True(t, saves > 0)
+
+	// offers declined without changing anything are not saved
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("2", 4, 4096)})
+	assert.Equal(t, 1, driver.DeclineOfferCount)
+	assert.Equal(t, saves, storage.SaveCount)
+}
+
+func TestSaveClusterStateUnchanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonsumer")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "gonsumer.json")
+	scheduler, err := NewScheduler(NewConfig(), NewFileStorage(file))
+	require.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		scheduler.Cluster().AddGroup(newTestGroup(fmt.Sprintf("group-%d", i)))
+	}
+
+	require.Nil(t, scheduler.SaveClusterState())
+	for i := 0; i < 10; i++ {
+		require.Nil(t, scheduler.SaveClusterState())
+	}
+
+	// saving unchanged state should not rotate backups
+	_, err = os.Stat(file + ".1")
+	assert.True(t, os.IsNotExist(err))
+
+	scheduler.Cluster().RemoveGroup("group-0")
+	require.Nil(t, scheduler.SaveClusterState())
+	_, err = os.Stat(file + ".1")
+	assert.Nil(t, err)
+}
@@ -6,7 +6,11 @@ import (
 	"strings"
 
 	"errors"
+	"fmt"
 	"github.com/yanzay/log"
+	"net/url"
+	"strconv"
+	"time"
 )
 
 type Server interface {
@@ -16,6 +20,7 @@ type Server interface {
 type HTTPServer struct {
 	address   string
 	scheduler Scheduler
+	election  LeaderElection
 }
 
 func NewHttpServer(address string, scheduler Scheduler) *HTTPServer {
@@ -28,13 +33,52 @@ func NewHttpServer(address string, scheduler Scheduler) *HTTPServer {
 	}
 }
 
+// SetLeaderElection makes this server redirect API requests to the leader unless this instance is the leader.
+func (s *HTTPServer) SetLeaderElection(election LeaderElection) {
+	s.election = election
+}
+
 func (s *HTTPServer) Start() error {
 	log.Infof("Starting HTTP server at %s", s.address)
-	http.HandleFunc("/api/group/add", s.groupAdd)
-	http.HandleFunc("/api/group/list", s.groupList)
+	http.HandleFunc("/api/group/add", s.leaderOnly(s.groupAdd))
+	http.HandleFunc("/api/group/list", s.leaderOnly(s.groupList))
+	http.HandleFunc("/api/group/update", s.leaderOnly(s.groupUpdate))
+	http.HandleFunc("/api/group/scale", s.leaderOnly(s.groupScale))
+	http.HandleFunc("/api/group/start", s.leaderOnly(s.groupStart))
+	http.HandleFunc("/api/group/stop", s.leaderOnly(s.groupStop))
+	http.HandleFunc("/api/group/remove", s.leaderOnly(s.groupRemove))
+	http.HandleFunc("/api/reconcile/start", s.leaderOnly(s.reconcileStart))
+	http.HandleFunc("/api/reconcile/status", s.leaderOnly(s.reconcileStatus))
+	http.HandleFunc("/api/status", s.leaderOnly(s.status))
+	http.HandleFunc(artifactsPath, s.artifact)
 	return http.ListenAndServe(s.address, nil)
 }
 
+// leaderOnly redirects requests to the leader while this instance is a standby, as its cluster state may be stale.
+func (s *HTTPServer) leaderOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.election == nil || s.election.IsLeader() {
+			handler(w, r)
+			return
+		}
+
+		leader, err := s.election.Leader()
+		if err != nil {
+			log.Errorf("Failed to get current leader: %s", err)
+		}
+
+		if leader == "" {
+			respond(w, http.StatusServiceUnavailable, ErrNoLeader)
+			return
+		}
+
+		if !strings.HasPrefix(leader, "http://") {
+			leader = "http://" + leader
+		}
+		http.Redirect(w, r, strings.TrimSuffix(leader, "/")+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	}
+}
+
 func (s *HTTPServer) groupAdd(w http.ResponseWriter, r *http.Request) {
 	cluster := s.scheduler.Cluster()
 	queryParams := r.URL.Query()
@@ -42,29 +86,307 @@ func (s *HTTPServer) groupAdd(w http.ResponseWriter, r *http.Request) {
 	groupID := queryParams.Get(ParamGroupID)
 	if groupID == "" {
 		respond(w, http.StatusBadRequest, ErrGroupIDRequired)
+		return
 	}
 
 	if cluster.ExistsGroup(groupID) {
 		respond(w, http.StatusBadRequest, ErrGroupExists)
+		return
 	}
 
 	subscription := queryParams.Get(ParamSubscription)
 	bootstrapBrokers := queryParams.Get(ParamBootstrapBrokers)
 
-	group := &Group{
-		ID:               groupID,
-		Subscriptions:    strings.Split(subscription, ","),
-		BootstrapBrokers: strings.Split(bootstrapBrokers, ","),
+	config := s.scheduler.Config()
+	group := NewGroup(groupID)
+	group.Subscriptions = strings.Split(subscription, ",")
+	group.BootstrapBrokers = strings.Split(bootstrapBrokers, ",")
+
+	var err error
+	group.Cpus, err = floatParam(queryParams, ParamCpus, config.ConsumerCpus)
+	if err != nil {
+		respond(w, http.StatusBadRequest, err)
+		return
+	}
+
+	group.Mem, err = floatParam(queryParams, ParamMem, config.ConsumerMem)
+	if err != nil {
+		respond(w, http.StatusBadRequest, err)
+		return
+	}
+
+	group.Disk, err = floatParam(queryParams, ParamDisk, config.ConsumerDisk)
+	if err != nil {
+		respond(w, http.StatusBadRequest, err)
+		return
+	}
+
+	group.Options, err = ParseOptions(queryParams.Get(ParamOptions))
+	if err != nil {
+		respond(w, http.StatusBadRequest, err)
+		return
 	}
 
-	cluster.AddGroup(group)
-	respond(w, http.StatusOK, nil)
+	group.Constraints, err = ParseConstraints(queryParams.Get(ParamConstraints))
+	if err != nil {
+		respond(w, http.StatusBadRequest, err)
+		return
+	}
+
+	group.Ports, err = intParam(queryParams, ParamPorts, 0)
+	if err != nil {
+		respond(w, http.StatusBadRequest, err)
+		return
+	}
+
+	group.UnreachableTimeout, err = durationParam(queryParams, ParamUnreachableTimeout, config.UnreachableTimeout)
+	if err != nil {
+		respond(w, http.StatusBadRequest, err)
+		return
+	}
+
+	group.KillGracePeriod, err = durationParam(queryParams, ParamKillGracePeriod, config.KillGracePeriod)
+	if err != nil {
+		respond(w, http.StatusBadRequest, err)
+		return
+	}
+
+	instances, err := intParam(queryParams, ParamInstances, 1)
+	if err != nil {
+		respond(w, http.StatusBadRequest, err)
+		return
+	}
+	group.Scale(instances)
+
+	respondResult(w, s.scheduler.AddGroup(group))
 }
 
 func (s *HTTPServer) groupList(w http.ResponseWriter, r *http.Request) {
-	cluster := s.scheduler.Cluster()
+	groups, err := s.scheduler.GroupsJSON()
+	if err != nil {
+		log.Errorf("Failed to list groups: %s", err)
+		respond(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+
+	respond(w, http.StatusOK, json.RawMessage(groups))
+}
+
+func (s *HTTPServer) groupUpdate(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+
+	groupID := queryParams.Get(ParamGroupID)
+	if groupID == "" {
+		respond(w, http.StatusBadRequest, ErrGroupIDRequired)
+		return
+	}
+
+	update, err := parseGroupUpdate(queryParams)
+	if err != nil {
+		respond(w, http.StatusBadRequest, err)
+		return
+	}
+
+	respondResult(w, s.scheduler.UpdateGroup(groupID, update))
+}
+
+// parseGroupUpdate creates a GroupUpdate containing only parameters present in a given query.
+func parseGroupUpdate(queryParams url.Values) (*GroupUpdate, error) {
+	update := new(GroupUpdate)
+	if _, ok := queryParams[ParamSubscription]; ok {
+		update.Subscriptions = strings.Split(queryParams.Get(ParamSubscription), ",")
+	}
+
+	if _, ok := queryParams[ParamBootstrapBrokers]; ok {
+		update.BootstrapBrokers = strings.Split(queryParams.Get(ParamBootstrapBrokers), ",")
+	}
+
+	var err error
+	update.Cpus, err = optionalFloatParam(queryParams, ParamCpus)
+	if err != nil {
+		return nil, err
+	}
+
+	update.Mem, err = optionalFloatParam(queryParams, ParamMem)
+	if err != nil {
+		return nil, err
+	}
+
+	update.Disk, err = optionalFloatParam(queryParams, ParamDisk)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := queryParams[ParamOptions]; ok {
+		options, err := ParseOptions(queryParams.Get(ParamOptions))
+		if err != nil {
+			return nil, err
+		}
+		update.Options = options
+	}
+
+	if _, ok := queryParams[ParamUnreachableTimeout]; ok {
+		unreachableTimeout, err := durationParam(queryParams, ParamUnreachableTimeout, 0)
+		if err != nil {
+			return nil, err
+		}
+		update.UnreachableTimeout = &unreachableTimeout
+	}
+
+	if _, ok := queryParams[ParamKillGracePeriod]; ok {
+		killGracePeriod, err := durationParam(queryParams, ParamKillGracePeriod, 0)
+		if err != nil {
+			return nil, err
+		}
+		update.KillGracePeriod = &killGracePeriod
+	}
+
+	return update, nil
+}
+
+func (s *HTTPServer) groupScale(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+
+	groupID := queryParams.Get(ParamGroupID)
+	if groupID == "" {
+		respond(w, http.StatusBadRequest, ErrGroupIDRequired)
+		return
+	}
+
+	if queryParams.Get(ParamInstances) == "" {
+		respond(w, http.StatusBadRequest, ErrInstancesRequired)
+		return
+	}
+
+	instances, err := intParam(queryParams, ParamInstances, 0)
+	if err != nil {
+		respond(w, http.StatusBadRequest, err)
+		return
+	}
+
+	respondResult(w, s.scheduler.ScaleGroup(groupID, instances))
+}
+
+func (s *HTTPServer) groupStart(w http.ResponseWriter, r *http.Request) {
+	groupID := r.URL.Query().Get(ParamGroupID)
+	if groupID == "" {
+		respond(w, http.StatusBadRequest, ErrGroupIDRequired)
+		return
+	}
+
+	respondResult(w, s.scheduler.StartGroup(groupID))
+}
+
+func (s *HTTPServer) groupStop(w http.ResponseWriter, r *http.Request) {
+	groupID := r.URL.Query().Get(ParamGroupID)
+	if groupID == "" {
+		respond(w, http.StatusBadRequest, ErrGroupIDRequired)
+		return
+	}
+
+	respondResult(w, s.scheduler.StopGroup(groupID))
+}
+
+func (s *HTTPServer) groupRemove(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+
+	groupID := queryParams.Get(ParamGroupID)
+	if groupID == "" {
+		respond(w, http.StatusBadRequest, ErrGroupIDRequired)
+		return
+	}
+
+	force := queryParams.Get(ParamForce) == "true"
+	respondResult(w, s.scheduler.RemoveGroup(groupID, force))
+}
+
+func (s *HTTPServer) reconcileStart(w http.ResponseWriter, r *http.Request) {
+	err := s.scheduler.Reconcile()
+	if err != nil {
+		respondResult(w, err)
+		return
+	}
 
-	respond(w, http.StatusOK, cluster.GetGroups())
+	respond(w, http.StatusOK, s.scheduler.ReconcileStatus())
+}
+
+func (s *HTTPServer) reconcileStatus(w http.ResponseWriter, r *http.Request) {
+	respond(w, http.StatusOK, s.scheduler.ReconcileStatus())
+}
+
+func (s *HTTPServer) status(w http.ResponseWriter, r *http.Request) {
+	respond(w, http.StatusOK, s.scheduler.Status())
+}
+
+// artifact serves the executor binary and extra artifacts to Mesos fetcher.
+func (s *HTTPServer) artifact(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, artifactsPath)
+	path, ok := artifacts(s.scheduler.Config())[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.ServeFile(w, r, path)
+}
+
+// floatParam parses a non-negative float query parameter, falling back to defaultValue if it is absent.
+func floatParam(queryParams url.Values, name string, defaultValue float64) (float64, error) {
+	rawValue := queryParams.Get(name)
+	if rawValue == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.ParseFloat(rawValue, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("Invalid value for parameter %s: %s", name, rawValue)
+	}
+
+	return value, nil
+}
+
+// intParam parses a non-negative integer query parameter, falling back to defaultValue if it is absent.
+func intParam(queryParams url.Values, name string, defaultValue int) (int, error) {
+	rawValue := queryParams.Get(name)
+	if rawValue == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(rawValue)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("Invalid value for parameter %s: %s", name, rawValue)
+	}
+
+	return value, nil
+}
+
+// durationParam parses a non-negative duration query parameter, e.g. 5m, falling back to defaultValue if it is absent.
+func durationParam(queryParams url.Values, name string, defaultValue time.Duration) (time.Duration, error) {
+	rawValue := queryParams.Get(name)
+	if rawValue == "" {
+		return defaultValue, nil
+	}
+
+	value, err := time.ParseDuration(rawValue)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("Invalid value for parameter %s: %s", name, rawValue)
+	}
+
+	return value, nil
+}
+
+// optionalFloatParam parses a non-negative float query parameter. Returns nil if the parameter is absent.
+func optionalFloatParam(queryParams url.Values, name string) (*float64, error) {
+	if _, ok := queryParams[name]; !ok {
+		return nil, nil
+	}
+
+	value, err := floatParam(queryParams, name, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &value, nil
 }
 
 func respond(w http.ResponseWriter, statusCode int, body interface{}) {
@@ -85,6 +407,23 @@ func respond(w http.ResponseWriter, statusCode int, body interface{}) {
 	}
 }
 
+// respondResult responds with an appropriate status code for an error returned by scheduler operations.
+func respondResult(w http.ResponseWriter, err error) {
+	switch err {
+	case nil:
+		respond(w, http.StatusOK, nil)
+	case ErrGroupNotFound:
+		respond(w, http.StatusNotFound, err)
+	case ErrGroupExists, ErrGroupNotStopped, ErrInvalidInstances:
+		respond(w, http.StatusBadRequest, err)
+	case ErrNotRegistered:
+		respond(w, http.StatusServiceUnavailable, err)
+	default:
+		log.Errorf("Group operation failed: %s", err)
+		respond(w, http.StatusInternalServerError, ErrInternal)
+	}
+}
+
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
@@ -96,7 +435,8 @@ func NewErrorResponse(msg string) *ErrorResponse {
 }
 
 var (
-	ErrGroupIDRequired = errors.New("Missing required parameter " + ParamGroupID)
-	ErrGroupExists     = errors.New("Group already exists")
-	ErrInternal        = errors.New("An error occurred")
+	ErrGroupIDRequired   = errors.New("Missing required parameter " + ParamGroupID)
+	ErrGroupExists       = errors.New("Group already exists")
+	ErrInstancesRequired = errors.New("Missing required parameter " + ParamInstances)
+	ErrInternal          = errors.New("An error occurred")
 )
@@ -0,0 +1,287 @@
+package framework
+
+import (
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	util "github.com/mesos/mesos-go/mesosutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServerGroupAdd(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	server := NewHttpServer("localhost:0", scheduler)
+
+	recorder := httptest.NewRecorder()
+	server.groupAdd(recorder, httptest.NewRequest("GET", "/api/group/add?group-id=foo&subscription=bar&bootstrap-brokers=localhost:9092&cpus=1.5", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	group := scheduler.Cluster().GetGroup("foo")
+	require.NotNil(t, group)
+	assert.Equal(t, []string{"bar"}, group.Subscriptions)
+	assert.Equal(t, []string{"localhost:9092"}, group.BootstrapBrokers)
+	assert.Equal(t, 1.5, group.Cpus)
+	assert.Equal(t, float64(DefaultConsumerMem), group.Mem)
+	assert.Equal(t, float64(DefaultConsumerDisk), group.Disk)
+
+	// group should not be added twice
+	recorder = httptest.NewRecorder()
+	server.groupAdd(recorder, httptest.NewRequest("GET", "/api/group/add?group-id=foo", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), ErrGroupExists.Error())
+
+	recorder = httptest.NewRecorder()
+	server.groupAdd(recorder, httptest.NewRequest("GET", "/api/group/add", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), ErrGroupIDRequired.Error())
+
+	recorder = httptest.NewRecorder()
+	server.groupAdd(recorder, httptest.NewRequest("GET", "/api/group/add?group-id=bar&mem=lots", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.False(t, scheduler.Cluster().ExistsGroup("bar"))
+
+	recorder = httptest.NewRecorder()
+	server.groupAdd(recorder, httptest.NewRequest("GET", "/api/group/add?group-id=bar&constraints=rack%3Dsomewhere", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "unknown operator somewhere")
+	assert.False(t, scheduler.Cluster().ExistsGroup("bar"))
+
+	recorder = httptest.NewRecorder()
+	server.groupAdd(recorder, httptest.NewRequest("GET", "/api/group/add?group-id=bar&constraints=hostname%3Dunique", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	require.Len(t, scheduler.Cluster().GetGroup("bar").Constraints, 1)
+
+	recorder = httptest.NewRecorder()
+	server.groupAdd(recorder, httptest.NewRequest("GET", "/api/group/add?group-id=baz&ports=2", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 2, scheduler.Cluster().GetGroup("baz").Ports)
+}
+
+func TestServerGroupStopStartRemove(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	server := NewHttpServer("localhost:0", scheduler)
+	scheduler.Cluster().AddGroup(newTestGroup("foo"))
+
+	recorder := httptest.NewRecorder()
+	server.groupRemove(recorder, httptest.NewRequest("GET", "/api/group/remove?group-id=foo", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), ErrGroupNotStopped.Error())
+
+	recorder = httptest.NewRecorder()
+	server.groupStop(recorder, httptest.NewRequest("GET", "/api/group/stop?group-id=foo", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.True(t, scheduler.Cluster().GetGroup("foo").Stopped)
+
+	recorder = httptest.NewRecorder()
+	server.groupStart(recorder, httptest.NewRequest("GET", "/api/group/start?group-id=foo", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.False(t, scheduler.Cluster().GetGroup("foo").Stopped)
+
+	recorder = httptest.NewRecorder()
+	server.groupStart(recorder, httptest.NewRequest("GET", "/api/group/start?group-id=bar", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	server.groupStop(recorder, httptest.NewRequest("GET", "/api/group/stop", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	server.groupRemove(recorder, httptest.NewRequest("GET", "/api/group/remove?group-id=foo&force=true", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.False(t, scheduler.Cluster().ExistsGroup("foo"))
+}
+
+func TestServerGroupUpdate(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	server := NewHttpServer("localhost:0", scheduler)
+	group := newTestGroup("foo")
+	group.Subscriptions = []string{"foo"}
+	scheduler.Cluster().AddGroup(group)
+
+	recorder := httptest.NewRecorder()
+	server.groupUpdate(recorder, httptest.NewRequest("GET", "/api/group/update?group-id=foo&cpus=2&options=a%3D1", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 2.0, group.Cpus)
+	assert.Equal(t, float64(DefaultConsumerMem), group.Mem)
+	assert.Equal(t, []string{"foo"}, group.Subscriptions)
+	assert.Equal(t, map[string]string{"a": "1"}, group.Options)
+
+	recorder = httptest.NewRecorder()
+	server.groupUpdate(recorder, httptest.NewRequest("GET", "/api/group/update?group-id=foo&unreachable-timeout=90s&kill-grace-period=1m", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 90*time.Second, group.UnreachableTimeout)
+	assert.Equal(t, time.Minute, group.KillGracePeriod)
+
+	recorder = httptest.NewRecorder()
+	server.groupUpdate(recorder, httptest.NewRequest("GET", "/api/group/update?group-id=foo&unreachable-timeout=-1m", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, 90*time.Second, group.UnreachableTimeout)
+
+	recorder = httptest.NewRecorder()
+	server.groupUpdate(recorder, httptest.NewRequest("GET", "/api/group/update?group-id=foo&mem=-1", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, float64(DefaultConsumerMem), group.Mem)
+
+	recorder = httptest.NewRecorder()
+	server.groupUpdate(recorder, httptest.NewRequest("GET", "/api/group/update?group-id=bar&mem=1", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestServerGroupScale(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	server := NewHttpServer("localhost:0", scheduler)
+
+	recorder := httptest.NewRecorder()
+	server.groupAdd(recorder, httptest.NewRequest("GET", "/api/group/add?group-id=foo&instances=2", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Len(t, scheduler.Cluster().GetGroup("foo").Consumers, 2)
+
+	recorder = httptest.NewRecorder()
+	server.groupScale(recorder, httptest.NewRequest("GET", "/api/group/scale?group-id=foo&instances=0", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 0, scheduler.Cluster().GetGroup("foo").Instances)
+
+	recorder = httptest.NewRecorder()
+	server.groupScale(recorder, httptest.NewRequest("GET", "/api/group/scale?group-id=foo", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), ErrInstancesRequired.Error())
+
+	recorder = httptest.NewRecorder()
+	server.groupScale(recorder, httptest.NewRequest("GET", "/api/group/scale?group-id=foo&instances=-2", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestServerGroupListConcurrently(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	server := NewHttpServer("localhost:0", scheduler)
+	driver := NewMockSchedulerDriver()
+	group := newTestGroup("foo")
+	scheduler.Cluster().AddGroup(group)
+	scheduler.ResourceOffers(driver, []*mesos.Offer{newTestOffer("1", 4, 4096)})
+	taskID := group.Consumers[0].TaskID
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			scheduler.StatusUpdate(driver, util.NewTaskStatus(util.NewTaskID(taskID), mesos.TaskState_TASK_RUNNING))
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		recorder := httptest.NewRecorder()
+		server.groupList(recorder, httptest.NewRequest("GET", "/api/group/list", nil))
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"id":"foo"`)
+	}
+	<-done
+}
+
+func TestServerArtifact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonsumer-artifacts")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	binary := filepath.Join(dir, "gonsumer-binary")
+	require.Nil(t, ioutil.WriteFile(binary, []byte("binary"), 0755))
+	artifact := filepath.Join(dir, "artifact.tar.gz")
+	require.Nil(t, ioutil.WriteFile(artifact, []byte("artifact"), 0644))
+
+	config := NewConfig()
+	config.ExecutorBinary = binary
+	config.Artifacts = []string{artifact}
+	scheduler, err := NewScheduler(config, NewMockStorage())
+	require.Nil(t, err)
+	server := NewHttpServer("localhost:0", scheduler)
+
+	// binary is served under its canonical name regardless of the local file name
+	recorder := httptest.NewRecorder()
+	server.artifact(recorder, httptest.NewRequest("GET", "/resource/gonsumer-mesos", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "binary", recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	server.artifact(recorder, httptest.NewRequest("GET", "/resource/artifact.tar.gz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "artifact", recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	server.artifact(recorder, httptest.NewRequest("GET", "/resource/gonsumer-binary", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestServerReconcile(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	server := NewHttpServer("localhost:0", scheduler)
+
+	recorder := httptest.NewRecorder()
+	server.reconcileStart(recorder, httptest.NewRequest("GET", "/api/reconcile/start", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), ErrNotRegistered.Error())
+
+	driver := NewMockSchedulerDriver()
+	scheduler.Registered(driver, util.NewFrameworkID("framework"), util.NewMasterInfo("master", 0, 5050))
+
+	recorder = httptest.NewRecorder()
+	server.reconcileStart(recorder, httptest.NewRequest("GET", "/api/reconcile/start", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"tries":1`)
+	assert.Equal(t, 2, driver.ReconcileTasksCount)
+
+	recorder = httptest.NewRecorder()
+	server.reconcileStatus(recorder, httptest.NewRequest("GET", "/api/reconcile/status", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"pending_tasks":[]`)
+}
+
+type testLeaderElection struct {
+	leader   string
+	isLeader bool
+}
+
+func (e *testLeaderElection) Campaign() error         { return nil }
+func (e *testLeaderElection) IsLeader() bool          { return e.isLeader }
+func (e *testLeaderElection) Leader() (string, error) { return e.leader, nil }
+func (e *testLeaderElection) Lost() <-chan struct{}   { return nil }
+func (e *testLeaderElection) Resign() error           { return nil }
+
+func TestServerLeaderOnly(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	server := NewHttpServer("localhost:0", scheduler)
+	election := &testLeaderElection{}
+	server.SetLeaderElection(election)
+	handler := server.leaderOnly(server.groupList)
+
+	// standby without an elected leader can't serve requests
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", "/api/group/list", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), ErrNoLeader.Error())
+
+	election.leader = "leader:6666"
+	recorder = httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", "/api/group/list?group-id=foo", nil))
+	assert.Equal(t, http.StatusTemporaryRedirect, recorder.Code)
+	assert.Equal(t, "http://leader:6666/api/group/list?group-id=foo", recorder.Header().Get("Location"))
+
+	election.isLeader = true
+	recorder = httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", "/api/group/list", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestServerStatus(t *testing.T) {
+	scheduler := newTestScheduler(t)
+	server := NewHttpServer("localhost:0", scheduler)
+
+	recorder := httptest.NewRecorder()
+	server.status(recorder, httptest.NewRequest("GET", "/api/status", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"registered":false`)
+}
@@ -1,12 +1,17 @@
 package framework
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/samuel/go-zookeeper/zk"
+	"github.com/yanzay/log"
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -15,50 +20,200 @@ type Storage interface {
 	Load() ([]byte, error)
 }
 
+// WatchableStorage is a Storage other processes can follow changes of.
+type WatchableStorage interface {
+	Storage
+	// Watch sends current contents and then contents after every change until stop is closed.
+	Watch(stop <-chan struct{}) (<-chan []byte, error)
+}
+
+// FileStorage keeps cluster state in a local file. Writes are atomic: contents go to a temporary file that is
+// fsynced and renamed over the target, so a crash never leaves a truncated file behind. Previous versions are kept
+// as numbered backups, file.1 being the newest, and are loaded instead of a corrupt file.
 type FileStorage struct {
 	file string
+	// Backups is how many previous versions to keep.
+	Backups int
 }
 
 func NewFileStorage(file string) *FileStorage {
 	return &FileStorage{
-		file: file,
+		file:    file,
+		Backups: DefaultFileStorageBackups,
 	}
 }
 
 func (fs *FileStorage) Save(contents []byte) error {
-	return ioutil.WriteFile(fs.file, contents, 0644)
+	// state is saved on every offer and status update, mostly unchanged, which must not push meaningful versions
+	// out of backups
+	current, err := ioutil.ReadFile(fs.file)
+	if err == nil && bytes.Equal(current, contents) {
+		return nil
+	}
+
+	dir, base := filepath.Split(fs.file)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := ioutil.TempFile(dir, base+".tmp")
+	if err != nil {
+		return err
+	}
+
+	err = writeSynced(tmp, contents)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	err = fs.rotateBackups()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), fs.file)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	// make the rename itself durable
+	return syncDir(dir)
 }
 
 func (fs *FileStorage) Load() ([]byte, error) {
-	data, err := ioutil.ReadFile(fs.file)
+	data, err := loadValidFile(fs.file)
+	if err == nil {
+		return data, nil
+	}
+
+	for i := 1; i <= fs.Backups; i++ {
+		backup := fs.backupFile(i)
+		data, backupErr := loadValidFile(backup)
+		if os.IsNotExist(backupErr) {
+			break
+		}
+
+		if backupErr == nil {
+			log.Warningf("Failed to load %s: %s, loaded backup %s instead", fs.file, err, backup)
+			return data, nil
+		}
+		log.Warningf("Failed to load backup %s: %s", backup, backupErr)
+	}
+
 	if os.IsNotExist(err) {
 		return nil, ErrStorageUninitialized
 	}
 
-	return data, err
+	return nil, err
 }
 
 func (fs *FileStorage) String() string {
 	return fmt.Sprintf("%s", fs.file)
 }
 
+func (fs *FileStorage) backupFile(n int) string {
+	return fmt.Sprintf("%s.%d", fs.file, n)
+}
+
+// rotateBackups shifts existing backups by one dropping the oldest and makes the current file the newest backup.
+// The current file is hard linked rather than moved, so it stays in place until it is replaced.
+func (fs *FileStorage) rotateBackups() error {
+	if fs.Backups <= 0 {
+		return nil
+	}
+
+	if _, err := os.Stat(fs.file); os.IsNotExist(err) {
+		return nil
+	}
+
+	err := os.Remove(fs.backupFile(fs.Backups))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for i := fs.Backups - 1; i >= 1; i-- {
+		err = os.Rename(fs.backupFile(i), fs.backupFile(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.Link(fs.file, fs.backupFile(1))
+}
+
+// loadValidFile reads a given file returning ErrStorageCorrupt if it does not hold a JSON document,
+// e.g. it was truncated by a crash.
+func loadValidFile(file string) ([]byte, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents interface{}
+	if json.Unmarshal(data, &contents) != nil {
+		return nil, ErrStorageCorrupt
+	}
+
+	return data, nil
+}
+
+func writeSynced(file *os.File, contents []byte) error {
+	_, err := file.Write(contents)
+	if err == nil {
+		err = file.Chmod(0644)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+// ZKStorage keeps cluster state in a ZooKeeper node over a single long-lived session. Writes are compare-and-set
+// against the version read last, so a write racing with another scheduler instance fails with ErrStorageConflict
+// instead of silently overwriting its state.
 type ZKStorage struct {
 	zkConnect string
 	zPath     string
+
+	lock sync.Mutex
+	conn *zk.Conn
+	// version is the node version read or written last, noVersion until the node is read.
+	version int32
 }
 
-func NewZKStorage(zk string) (*ZKStorage, error) {
-	zkConnect := zk
-	path := "/"
-	chrootIdx := strings.Index(zk, "/")
-	if chrootIdx != -1 {
-		zkConnect = zk[:chrootIdx]
-		path = zk[chrootIdx:]
-	}
+const (
+	noVersion = -1
 
+	zkRetryDelay    = 100 * time.Millisecond
+	zkRetryMaxDelay = 5 * time.Second
+	zkRetryTimeout  = 30 * time.Second
+)
+
+func NewZKStorage(zk string) (*ZKStorage, error) {
+	zkConnect, path := splitZkConnect(zk)
 	storage := &ZKStorage{
 		zkConnect: zkConnect,
 		zPath:     path,
+		version:   noVersion,
 	}
 
 	err := storage.createChrootIfRequired()
@@ -66,59 +221,249 @@ func NewZKStorage(zk string) (*ZKStorage, error) {
 }
 
 func (zs *ZKStorage) Save(contents []byte) error {
-	conn, err := zs.newZkClient()
+	zs.lock.Lock()
+	defer zs.lock.Unlock()
+
+	retried := false
+	return zs.retry(func(conn *zk.Conn) error {
+		err := zs.set(conn, contents, retried)
+		retried = true
+		return err
+	})
+}
+
+// zkNodeWriter is the part of a ZooKeeper session ZKStorage writes the node through.
+type zkNodeWriter interface {
+	Get(path string) ([]byte, *zk.Stat, error)
+	Set(path string, data []byte, version int32) (*zk.Stat, error)
+}
+
+// set writes the node unless it was modified since it was read. A write retried after a connection error may have
+// been applied already with the response lost, so a version mismatch is not a conflict if the node holds given
+// contents. Must be called with lock held.
+func (zs *ZKStorage) set(conn zkNodeWriter, contents []byte, retried bool) error {
+	if zs.version == noVersion {
+		// nothing was read yet, so there is nothing to compare with
+		_, stat, err := conn.Get(zs.zPath)
+		if err != nil {
+			return err
+		}
+		zs.version = stat.Version
+	}
+
+	stat, err := conn.Set(zs.zPath, contents, zs.version)
+	if err == zk.ErrBadVersion && retried {
+		data, current, err := conn.Get(zs.zPath)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(data, contents) {
+			zs.version = current.Version
+			return nil
+		}
+	}
+	if err == zk.ErrBadVersion {
+		return ErrStorageConflict
+	}
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
 
-	_, stat, _ := conn.Get(zs.zPath)
-	_, err = conn.Set(zs.zPath, contents, stat.Version)
-	return err
+	zs.version = stat.Version
+	return nil
 }
 
 func (zs *ZKStorage) Load() ([]byte, error) {
-	conn, err := zs.newZkClient()
-	if err != nil {
-		return nil, err
-	}
-	defer conn.Close()
+	zs.lock.Lock()
+	defer zs.lock.Unlock()
+
+	var contents []byte
+	err := zs.retry(func(conn *zk.Conn) error {
+		data, stat, err := conn.Get(zs.zPath)
+		if err != nil {
+			return err
+		}
 
-	contents, _, err := conn.Get(zs.zPath)
-	if len(contents) == 0 {
+		contents = data
+		zs.version = stat.Version
+		return nil
+	})
+	if err == zk.ErrNoNode || (err == nil && len(contents) == 0) {
 		return nil, ErrStorageUninitialized
 	}
 
 	return contents, err
 }
 
+// Watch sends current contents of the node and then its contents after every change until stop is closed.
+// Watching does not affect the version compared by Save.
+func (zs *ZKStorage) Watch(stop <-chan struct{}) (<-chan []byte, error) {
+	zs.lock.Lock()
+	_, err := zs.session()
+	zs.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(chan []byte)
+	go func() {
+		defer close(changes)
+
+		delay := zkRetryDelay
+		for {
+			// the session may be replaced in the meantime
+			zs.lock.Lock()
+			conn, err := zs.session()
+			zs.lock.Unlock()
+
+			var contents []byte
+			var events <-chan zk.Event
+			if err == nil {
+				contents, _, events, err = conn.GetW(zs.zPath)
+			}
+			if err != nil {
+				log.Warningf("Failed to watch %s: %s, retrying in %s", zs, err, delay)
+				select {
+				case <-time.After(delay):
+					delay = nextZkRetryDelay(delay)
+					continue
+				case <-stop:
+					return
+				}
+			}
+			delay = zkRetryDelay
+
+			select {
+			case changes <- contents:
+			case <-stop:
+				return
+			}
+
+			select {
+			case <-events:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+// Close ends the ZooKeeper session.
+func (zs *ZKStorage) Close() {
+	zs.lock.Lock()
+	defer zs.lock.Unlock()
+
+	if zs.conn != nil {
+		zs.conn.Close()
+		zs.conn = nil
+	}
+}
+
 func (zs *ZKStorage) String() string {
 	return fmt.Sprintf("%s%s", zs.zkConnect, zs.zPath)
 }
 
 func (zs *ZKStorage) createChrootIfRequired() error {
-	if zs.zPath != "" {
-		conn, err := zs.newZkClient()
+	if zs.zPath == "" {
+		return nil
+	}
+
+	zs.lock.Lock()
+	defer zs.lock.Unlock()
+
+	return zs.retry(func(conn *zk.Conn) error {
+		return createZPath(conn, zs.zPath)
+	})
+}
+
+// session returns the ZooKeeper session, establishing it first if there is none. Must be called with lock held.
+func (zs *ZKStorage) session() (*zk.Conn, error) {
+	if zs.conn != nil {
+		return zs.conn, nil
+	}
+
+	conn, events, err := zk.Connect([]string{zs.zkConnect}, zkSessionTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	zs.conn = conn
+	go zs.watchSession(events)
+	return conn, nil
+}
+
+func (zs *ZKStorage) watchSession(events <-chan zk.Event) {
+	for event := range events {
+		switch event.State {
+		case zk.StateExpired:
+			log.Warningf("ZooKeeper session of %s expired, a new one is established on reconnect", zs)
+		case zk.StateDisconnected:
+			log.Warningf("Disconnected from ZooKeeper %s", zs.zkConnect)
+		case zk.StateHasSession:
+			log.Debugf("Connected to ZooKeeper %s", zs.zkConnect)
+		}
+	}
+}
+
+// retry runs a given operation, retrying it with exponential backoff while ZooKeeper is unavailable for up to
+// zkRetryTimeout. A closed session is replaced with a new one. Must be called with lock held.
+func (zs *ZKStorage) retry(operation func(conn *zk.Conn) error) error {
+	delay := zkRetryDelay
+	deadline := time.Now().Add(zkRetryTimeout)
+	for {
+		conn, err := zs.session()
 		if err != nil {
 			return err
 		}
-		defer conn.Close()
 
-		err = zs.createZPath(conn, zs.zPath)
-		if err != nil {
+		err = operation(conn)
+		if !isZkConnectionError(err) || time.Now().Add(delay).After(deadline) {
 			return err
 		}
+
+		if err == zk.ErrClosing || err == zk.ErrConnectionClosed {
+			conn.Close()
+			zs.conn = nil
+		}
+
+		log.Warningf("ZooKeeper %s is unavailable: %s, retrying in %s", zs.zkConnect, err, delay)
+		time.Sleep(delay)
+		delay = nextZkRetryDelay(delay)
 	}
+}
 
-	return nil
+func nextZkRetryDelay(delay time.Duration) time.Duration {
+	delay *= 2
+	if delay > zkRetryMaxDelay {
+		delay = zkRetryMaxDelay
+	}
+
+	return delay
 }
 
-func (zs *ZKStorage) newZkClient() (*zk.Conn, error) {
-	conn, _, err := zk.Connect([]string{zs.zkConnect}, 30*time.Second)
-	return conn, err
+func isZkConnectionError(err error) bool {
+	switch err {
+	case zk.ErrNoServer, zk.ErrConnectionClosed, zk.ErrSessionExpired, zk.ErrClosing:
+		return true
+	default:
+		return false
+	}
 }
 
-func (zs *ZKStorage) createZPath(conn *zk.Conn, zpath string) error {
+// splitZkConnect splits a connect string in form host:port/path to ZooKeeper address and path.
+func splitZkConnect(zk string) (string, string) {
+	chrootIdx := strings.Index(zk, "/")
+	if chrootIdx == -1 {
+		return zk, "/"
+	}
+
+	return zk[:chrootIdx], zk[chrootIdx:]
+}
+
+func createZPath(conn *zk.Conn, zpath string) error {
 	_, err := conn.Create(zpath, nil, 0, zk.WorldACL(zk.PermAll))
 	if err != nil {
 		if zk.ErrNodeExists == err {
@@ -128,7 +473,7 @@ func (zs *ZKStorage) createZPath(conn *zk.Conn, zpath string) error {
 			if len(parent) == 0 {
 				return ErrEmptyZPath
 			}
-			err = zs.createZPath(conn, parent[:len(parent)-1])
+			err = createZPath(conn, parent[:len(parent)-1])
 			if err != nil {
 				return err
 			}
@@ -147,18 +492,64 @@ func (zs *ZKStorage) createZPath(conn *zk.Conn, zpath string) error {
 	}
 }
 
+// StorageFactory creates a storage from the part of a storage string following the scheme, e.g. host:port/path
+// for zk:host:port/path.
+type StorageFactory func(connect string) (Storage, error)
+
+var (
+	storageFactoriesLock sync.RWMutex
+	storageFactories     = map[string]StorageFactory{
+		"file": func(connect string) (Storage, error) {
+			return NewFileStorage(connect), nil
+		},
+		"zk": func(connect string) (Storage, error) {
+			return NewZKStorage(connect)
+		},
+		"etcd": func(connect string) (Storage, error) {
+			return NewEtcdStorage(connect)
+		},
+		"consul": func(connect string) (Storage, error) {
+			return NewConsulStorage(connect)
+		},
+		"mem": func(connect string) (Storage, error) {
+			return NewMemStorage(connect), nil
+		},
+	}
+)
+
+// RegisterStorage makes NewStorage create storages with a given scheme using a given factory. It panics if the scheme
+// is empty, contains a colon or is already registered, so it is meant to be called from an init function.
+func RegisterStorage(scheme string, factory StorageFactory) {
+	if scheme == "" || strings.Contains(scheme, ":") {
+		panic(fmt.Sprintf("Invalid storage scheme %q", scheme))
+	}
+
+	if factory == nil {
+		panic(fmt.Sprintf("Nil factory for storage scheme %s", scheme))
+	}
+
+	storageFactoriesLock.Lock()
+	defer storageFactoriesLock.Unlock()
+
+	if _, exists := storageFactories[scheme]; exists {
+		panic(fmt.Sprintf("Storage scheme %s is already registered", scheme))
+	}
+	storageFactories[scheme] = factory
+}
+
+// NewStorage creates a storage from a string in form scheme:connect using the factory registered for the scheme.
 func NewStorage(storage string) (Storage, error) {
 	storageTokens := strings.SplitN(storage, ":", 2)
 	if len(storageTokens) != 2 {
 		return nil, ErrUnsupportedStorage
 	}
 
-	switch storageTokens[0] {
-	case "file":
-		return NewFileStorage(storageTokens[1]), nil
-	case "zk":
-		return NewZKStorage(storageTokens[1])
-	default:
+	storageFactoriesLock.RLock()
+	factory, exists := storageFactories[storageTokens[0]]
+	storageFactoriesLock.RUnlock()
+	if !exists {
 		return nil, ErrUnsupportedStorage
 	}
+
+	return factory(storageTokens[1])
 }
@@ -5,7 +5,9 @@ import (
 	"github.com/samuel/go-zookeeper/zk"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -13,9 +15,10 @@ import (
 
 func TestFileStorage(t *testing.T) {
 	file := "tmp_file_storage.txt"
-	contents := "hello world"
+	contents := `{"hello": "world"}`
 	defer func() {
 		os.Remove(file)
+		os.Remove(file + ".1")
 	}()
 
 	storage := NewFileStorage(file)
@@ -29,6 +32,71 @@ func TestFileStorage(t *testing.T) {
 	assert.Equal(t, file, storage.String())
 }
 
+func TestFileStorageBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonsumer")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "gonsumer.json")
+	storage := NewFileStorage(file)
+	storage.Backups = 2
+	for i := 1; i <= 4; i++ {
+		require.Nil(t, storage.Save([]byte(fmt.Sprintf(`{"version": %d}`, i))))
+	}
+
+	assertFile(t, file, `{"version": 4}`)
+	assertFile(t, file+".1", `{"version": 3}`)
+	assertFile(t, file+".2", `{"version": 2}`)
+	_, err = os.Stat(file + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	// saving unchanged contents does not shift backups
+	require.Nil(t, storage.Save([]byte(`{"version": 4}`)))
+	assertFile(t, file, `{"version": 4}`)
+	assertFile(t, file+".1", `{"version": 3}`)
+	assertFile(t, file+".2", `{"version": 2}`)
+
+	// no temporary files should be left behind
+	files, err := ioutil.ReadDir(dir)
+	require.Nil(t, err)
+	assert.Len(t, files, 3)
+}
+
+func TestFileStorageCorrupt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonsumer")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "gonsumer.json")
+	storage := NewFileStorage(file)
+	require.Nil(t, storage.Save([]byte(`{"version": 1}`)))
+	require.Nil(t, storage.Save([]byte(`{"version": 2}`)))
+
+	// truncated file falls back to the newest valid backup
+	require.Nil(t, ioutil.WriteFile(file, []byte(`{"vers`), 0644))
+	contents, err := storage.Load()
+	require.Nil(t, err)
+	assert.Equal(t, `{"version": 1}`, string(contents))
+
+	// missing file falls back too
+	require.Nil(t, os.Remove(file))
+	contents, err = storage.Load()
+	require.Nil(t, err)
+	assert.Equal(t, `{"version": 1}`, string(contents))
+
+	// corrupt backups are skipped
+	require.Nil(t, ioutil.WriteFile(file, []byte(`{"vers`), 0644))
+	require.Nil(t, ioutil.WriteFile(file+".1", []byte(""), 0644))
+	_, err = storage.Load()
+	assert.Equal(t, ErrStorageCorrupt, err)
+}
+
+func assertFile(t *testing.T, file string, contents string) {
+	data, err := ioutil.ReadFile(file)
+	require.Nil(t, err)
+	assert.Equal(t, contents, string(data))
+}
+
 func TestZKStorage(t *testing.T) {
 	zkConnect := "localhost:2181"
 	zpath := "/tmp/zk/storage"
@@ -54,11 +122,92 @@ func TestZKStorage(t *testing.T) {
 	require.Nil(t, err)
 	assert.Equal(t, contents, string(loadedContents))
 
+	stop := make(chan struct{})
+	defer close(stop)
+	changes, err := storage.Watch(stop)
+	require.Nil(t, err)
+	assert.Equal(t, contents, string(awaitChange(t, changes)))
+
+	// a write made by another instance in the meantime is not overwritten
+	other, err := NewZKStorage(fmt.Sprintf("%s%s", zkConnect, zpath))
+	require.Nil(t, err)
+	defer other.Close()
+	_, err = other.Load()
+	require.Nil(t, err)
+	require.Nil(t, other.Save([]byte("other")))
+	assert.Equal(t, "other", string(awaitChange(t, changes)))
+	assert.Equal(t, ErrStorageConflict, storage.Save([]byte("stale")))
+
+	// writing is possible again once the new version is read
+	loadedContents, err = storage.Load()
+	require.Nil(t, err)
+	assert.Equal(t, "other", string(loadedContents))
+	require.Nil(t, storage.Save([]byte(contents)))
+	assert.Equal(t, contents, string(awaitChange(t, changes)))
+	storage.Close()
+
 	err = zkDelete(conn, zpath)
 	require.Nil(t, err)
 	assert.Equal(t, fmt.Sprintf("%s%s", zkConnect, zpath), storage.String())
 }
 
+// fakeZkNode is a ZooKeeper node which can lose responses to writes it has applied.
+type fakeZkNode struct {
+	data    []byte
+	version int32
+	// loseResponse makes the next write fail as if the connection was closed after the write was applied.
+	loseResponse bool
+}
+
+func (n *fakeZkNode) Get(string) ([]byte, *zk.Stat, error) {
+	return n.data, &zk.Stat{Version: n.version}, nil
+}
+
+func (n *fakeZkNode) Set(_ string, data []byte, version int32) (*zk.Stat, error) {
+	if version != n.version {
+		return nil, zk.ErrBadVersion
+	}
+
+	n.data = data
+	n.version++
+	if n.loseResponse {
+		n.loseResponse = false
+		return nil, zk.ErrConnectionClosed
+	}
+
+	return &zk.Stat{Version: n.version}, nil
+}
+
+func TestZKStorageRetriedSave(t *testing.T) {
+	storage := &ZKStorage{zPath: "/gonsumer", version: noVersion}
+	node := &fakeZkNode{}
+
+	// a write applied without a response is not a conflict once retried
+	node.loseResponse = true
+	assert.Equal(t, zk.ErrConnectionClosed, storage.set(node, []byte("state"), false))
+	require.Nil(t, storage.set(node, []byte("state"), true))
+	assert.Equal(t, node.version, storage.version)
+	require.Nil(t, storage.set(node, []byte("next"), false))
+	assert.Equal(t, "next", string(node.data))
+
+	// a write made by another instance in the meantime is a conflict whether retried or not
+	_, err := node.Set("/gonsumer", []byte("other"), node.version)
+	require.Nil(t, err)
+	assert.Equal(t, ErrStorageConflict, storage.set(node, []byte("stale"), true))
+	assert.Equal(t, ErrStorageConflict, storage.set(node, []byte("stale"), false))
+	assert.Equal(t, "other", string(node.data))
+}
+
+func awaitChange(t *testing.T, changes <-chan []byte) []byte {
+	select {
+	case contents := <-changes:
+		return contents
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a storage change")
+		return nil
+	}
+}
+
 func zkDelete(conn *zk.Conn, zpath string) error {
 	if zpath != "" {
 		_, stat, _ := conn.Get(zpath)
@@ -77,3 +226,73 @@ func zkDelete(conn *zk.Conn, zpath string) error {
 		return nil
 	}
 }
+
+func TestMemStorage(t *testing.T) {
+	storage, err := NewStorage("mem:TestMemStorage")
+	require.Nil(t, err)
+	assert.Equal(t, "mem:TestMemStorage", fmt.Sprint(storage))
+
+	_, err = storage.Load()
+	assert.Equal(t, ErrStorageUninitialized, err)
+
+	contents := []byte("hello world")
+	require.Nil(t, storage.Save(contents))
+	contents[0] = 'j'
+	loadedContents, err := storage.Load()
+	require.Nil(t, err)
+	assert.Equal(t, "hello world", string(loadedContents))
+
+	// storages of the same name share contents
+	other := NewMemStorage("TestMemStorage")
+	loadedContents, err = other.Load()
+	require.Nil(t, err)
+	assert.Equal(t, "hello world", string(loadedContents))
+	require.Nil(t, other.Save([]byte("other")))
+	assert.Equal(t, ErrStorageConflict, storage.Save([]byte("stale")))
+
+	loadedContents, err = storage.Load()
+	require.Nil(t, err)
+	assert.Equal(t, "other", string(loadedContents))
+	require.Nil(t, storage.Save([]byte("hello world")))
+
+	_, err = NewMemStorage("TestMemStorageUnrelated").Load()
+	assert.Equal(t, ErrStorageUninitialized, err)
+}
+
+func TestRegisterStorage(t *testing.T) {
+	_, err := NewStorage("test-register:connect")
+	assert.Equal(t, ErrUnsupportedStorage, err)
+
+	var connect string
+	mockStorage := NewMockStorage()
+	RegisterStorage("test-register", func(c string) (Storage, error) {
+		connect = c
+		return mockStorage, nil
+	})
+
+	storage, err := NewStorage("test-register:host:1234/path")
+	require.Nil(t, err)
+	assert.Equal(t, mockStorage, storage)
+	assert.Equal(t, "host:1234/path", connect)
+
+	assert.Panics(t, func() {
+		RegisterStorage("test-register", func(string) (Storage, error) { return nil, nil })
+	})
+	assert.Panics(t, func() {
+		RegisterStorage("file", func(string) (Storage, error) { return nil, nil })
+	})
+	assert.Panics(t, func() {
+		RegisterStorage("", func(string) (Storage, error) { return nil, nil })
+	})
+	assert.Panics(t, func() {
+		RegisterStorage("test:register", func(string) (Storage, error) { return nil, nil })
+	})
+	assert.Panics(t, func() {
+		RegisterStorage("test-register-nil", nil)
+	})
+
+	_, err = NewStorage("unknown:connect")
+	assert.Equal(t, ErrUnsupportedStorage, err)
+	_, err = NewStorage("file")
+	assert.Equal(t, ErrUnsupportedStorage, err)
+}
@@ -0,0 +1,98 @@
+package framework
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	util "github.com/mesos/mesos-go/mesosutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const executorCommand = "./gonsumer-mesos executor"
+
+const (
+	portEnvPrefix = "PORT"
+	portsEnv      = "PORTS"
+)
+
+// ConsumerConfig is the part of group configuration an executor needs to run a consumer of the group. It is passed
+// to executors as task data.
+type ConsumerConfig struct {
+	GroupID          string            `json:"group_id"`
+	Subscriptions    []string          `json:"subscriptions"`
+	BootstrapBrokers []string          `json:"bootstrap_brokers"`
+	Options          map[string]string `json:"options,omitempty"`
+}
+
+func newConsumerConfig(group *Group) *ConsumerConfig {
+	return &ConsumerConfig{
+		GroupID:          group.ID,
+		Subscriptions:    group.Subscriptions,
+		BootstrapBrokers: group.BootstrapBrokers,
+		Options:          group.Options,
+	}
+}
+
+func newTaskID(group *Group, consumer *Consumer) string {
+	return fmt.Sprintf("%s-%s-%d", group.ID, consumer.ID, time.Now().UnixNano())
+}
+
+func newTaskInfo(config GonsumerFrameworkConfig, group *Group, consumer *Consumer, offer *mesos.Offer, resources []*mesos.Resource, ports []uint64) (*mesos.TaskInfo, error) {
+	// state of the group's consumers is of no use to the executor
+	data, err := json.Marshal(newConsumerConfig(group))
+	if err != nil {
+		return nil, err
+	}
+
+	taskName := fmt.Sprintf("gonsumer-%s-%s", group.ID, consumer.ID)
+	taskID := newTaskID(group, consumer)
+
+	return &mesos.TaskInfo{
+		Name:    proto.String(taskName),
+		TaskId:  util.NewTaskID(taskID),
+		SlaveId: offer.GetSlaveId(),
+		Executor: &mesos.ExecutorInfo{
+			ExecutorId: util.NewExecutorID(taskID),
+			Name:       proto.String(taskName),
+			Command: &mesos.CommandInfo{
+				Value:       proto.String(executorCommand),
+				Uris:        artifactURIs(config),
+				Environment: portsEnvironment(ports),
+			},
+		},
+		Resources: resources,
+		// the executor gets this long to commit offsets and leave the consumer group once the task is killed
+		KillPolicy: &mesos.KillPolicy{
+			GracePeriod: &mesos.DurationInfo{Nanoseconds: proto.Int64(int64(group.KillGracePeriod))},
+		},
+		Data: data,
+	}, nil
+}
+
+// portsEnvironment exposes allocated ports to the executor as PORT0..PORTn variables and a comma separated PORTS list.
+func portsEnvironment(ports []uint64) *mesos.Environment {
+	if len(ports) == 0 {
+		return nil
+	}
+
+	variables := make([]*mesos.Environment_Variable, 0, len(ports)+1)
+	portStrings := make([]string, 0, len(ports))
+	for i, port := range ports {
+		portString := strconv.FormatUint(port, 10)
+		portStrings = append(portStrings, portString)
+		variables = append(variables, &mesos.Environment_Variable{
+			Name:  proto.String(fmt.Sprintf("%s%d", portEnvPrefix, i)),
+			Value: proto.String(portString),
+		})
+	}
+
+	variables = append(variables, &mesos.Environment_Variable{
+		Name:  proto.String(portsEnv),
+		Value: proto.String(strings.Join(portStrings, ",")),
+	})
+
+	return &mesos.Environment{Variables: variables}
+}
@@ -0,0 +1,280 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Mesos v1 API speaks JSON with field names of v1 protobufs. Those mostly match v0 protobufs used by the rest
+// of the framework except slaves were renamed to agents, so v0 messages are converted to and from JSON field by field.
+
+var v1FieldRenames = map[string]string{
+	"slave_id": "agent_id",
+}
+
+var v1EnumPrefixRenames = map[string]string{
+	"SOURCE_SLAVE":  "SOURCE_AGENT",
+	"REASON_SLAVE_": "REASON_AGENT_",
+}
+
+var (
+	stringerType        = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// v1Marshal converts a v0 message to a value encoding/json marshals as a corresponding v1 message.
+func v1Marshal(message interface{}) interface{} {
+	return v1Encode(reflect.ValueOf(message))
+}
+
+// v1Unmarshal decodes a JSON encoded v1 message to a given v0 message. Unknown fields are ignored.
+func v1Unmarshal(data []byte, message interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var raw interface{}
+	err := decoder.Decode(&raw)
+	if err != nil {
+		return err
+	}
+
+	return v1Decode(raw, reflect.ValueOf(message))
+}
+
+func v1Encode(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return v1Encode(value.Elem())
+	case reflect.Struct:
+		fields := make(map[string]interface{})
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.PkgPath != "" || strings.HasPrefix(field.Name, "XXX_") {
+				continue
+			}
+
+			encoded := v1Encode(value.Field(i))
+			if encoded != nil {
+				fields[v1FieldName(field.Name)] = encoded
+			}
+		}
+		return fields
+	case reflect.Slice:
+		if value.IsNil() {
+			return nil
+		}
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return value.Bytes() // encoding/json takes care of base64
+		}
+
+		items := make([]interface{}, value.Len())
+		for i := range items {
+			items[i] = v1Encode(value.Index(i))
+		}
+		return items
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+		return value.Interface()
+	case reflect.Int32:
+		if v1IsEnum(value.Type()) {
+			return v1EnumName(fmt.Sprint(value.Interface()))
+		}
+		return value.Int()
+	default:
+		return value.Interface()
+	}
+}
+
+func v1Decode(raw interface{}, value reflect.Value) error {
+	if raw == nil {
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return v1Decode(raw, value.Elem())
+	case reflect.Struct:
+		fields, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Expected JSON object for %s, got %v", value.Type(), raw)
+		}
+
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.PkgPath != "" || strings.HasPrefix(field.Name, "XXX_") {
+				continue
+			}
+
+			rawField, ok := fields[v1FieldName(field.Name)]
+			if !ok {
+				continue
+			}
+
+			err := v1Decode(rawField, value.Field(i))
+			if err != nil {
+				return fmt.Errorf("%s: %s", v1FieldName(field.Name), err)
+			}
+		}
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			encoded, ok := raw.(string)
+			if !ok {
+				return fmt.Errorf("Expected base64 string, got %v", raw)
+			}
+
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return err
+			}
+			value.SetBytes(decoded)
+			return nil
+		}
+
+		items, ok := raw.([]interface{})
+		if !ok {
+			return fmt.Errorf("Expected JSON array for %s, got %v", value.Type(), raw)
+		}
+
+		slice := reflect.MakeSlice(value.Type(), len(items), len(items))
+		for i, item := range items {
+			err := v1Decode(item, slice.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+	case reflect.String:
+		str, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("Expected string, got %v", raw)
+		}
+		value.SetString(str)
+	case reflect.Bool:
+		b, ok := raw.(bool)
+		if !ok {
+			return fmt.Errorf("Expected boolean, got %v", raw)
+		}
+		value.SetBool(b)
+	case reflect.Float32, reflect.Float64:
+		number, err := v1Number(raw)
+		if err != nil {
+			return err
+		}
+
+		f, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	case reflect.Int32, reflect.Int64:
+		if v1IsEnum(value.Type()) {
+			name, ok := raw.(string)
+			if !ok {
+				return fmt.Errorf("Expected %s name, got %v", value.Type(), raw)
+			}
+
+			// generated enums look their names up themselves
+			encoded, err := json.Marshal(v0EnumName(name))
+			if err != nil {
+				return err
+			}
+			return value.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(encoded)
+		}
+
+		number, err := v1Number(raw)
+		if err != nil {
+			return err
+		}
+
+		i, err := strconv.ParseInt(number, 10, 64)
+		if err != nil {
+			return err
+		}
+		value.SetInt(i)
+	case reflect.Uint32, reflect.Uint64:
+		number, err := v1Number(raw)
+		if err != nil {
+			return err
+		}
+
+		u, err := strconv.ParseUint(number, 10, 64)
+		if err != nil {
+			return err
+		}
+		value.SetUint(u)
+	}
+
+	return nil
+}
+
+// v1IsEnum tells whether a given type is a protobuf enum. Unlike plain integer fields, generated enum types have
+// names and unmarshal themselves from JSON names.
+func v1IsEnum(t reflect.Type) bool {
+	return t.Kind() == reflect.Int32 && t.Implements(stringerType) && reflect.PtrTo(t).Implements(jsonUnmarshalerType)
+}
+
+func v1Number(raw interface{}) (string, error) {
+	switch number := raw.(type) {
+	case json.Number:
+		return number.String(), nil
+	case string:
+		// 64 bit integers may come as strings
+		return number, nil
+	default:
+		return "", fmt.Errorf("Expected number, got %v", raw)
+	}
+}
+
+// v1FieldName converts a v0 Go field name, e.g. SlaveId, to a v1 JSON field name, e.g. agent_id.
+func v1FieldName(goName string) string {
+	var name bytes.Buffer
+	for i, r := range goName {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				name.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		name.WriteRune(r)
+	}
+
+	if renamed, ok := v1FieldRenames[name.String()]; ok {
+		return renamed
+	}
+
+	return name.String()
+}
+
+func v1EnumName(v0Name string) string {
+	for v0Prefix, v1Prefix := range v1EnumPrefixRenames {
+		if strings.HasPrefix(v0Name, v0Prefix) {
+			return v1Prefix + strings.TrimPrefix(v0Name, v0Prefix)
+		}
+	}
+
+	return v0Name
+}
+
+func v0EnumName(v1Name string) string {
+	for v0Prefix, v1Prefix := range v1EnumPrefixRenames {
+		if strings.HasPrefix(v1Name, v1Prefix) {
+			return v0Prefix + strings.TrimPrefix(v1Name, v1Prefix)
+		}
+	}
+
+	return v1Name
+}
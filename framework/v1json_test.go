@@ -0,0 +1,130 @@
+package framework
+
+import (
+	"encoding/json"
+	mesos "github.com/mesos/mesos-go/mesosproto"
+	util "github.com/mesos/mesos-go/mesosutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestV1JSON(t *testing.T) {
+	status := util.NewTaskStatus(util.NewTaskID("task"), mesos.TaskState_TASK_LOST)
+	status.SlaveId = util.NewSlaveID("agent")
+	status.Source = mesos.TaskStatus_SOURCE_SLAVE.Enum()
+	status.Reason = mesos.TaskStatus_REASON_SLAVE_REMOVED.Enum()
+	status.Uuid = []byte("uuid")
+
+	data, err := json.Marshal(v1Marshal(status))
+	require.Nil(t, err)
+	assert.JSONEq(t, `{
+		"task_id": {"value": "task"},
+		"state": "TASK_LOST",
+		"agent_id": {"value": "agent"},
+		"source": "SOURCE_AGENT",
+		"reason": "REASON_AGENT_REMOVED",
+		"uuid": "dXVpZA=="
+	}`, string(data))
+
+	decoded := new(mesos.TaskStatus)
+	require.Nil(t, v1Unmarshal(data, decoded))
+	assert.Equal(t, status, decoded)
+
+	offer := new(mesos.Offer)
+	err = v1Unmarshal([]byte(`{
+		"id": {"value": "offer"},
+		"agent_id": {"value": "agent"},
+		"hostname": "host",
+		"resources": [
+			{"name": "cpus", "type": "SCALAR", "scalar": {"value": 1.5}, "role": "*"},
+			{"name": "ports", "type": "RANGES", "ranges": {"range": [{"begin": 31000, "end": 32000}]}}
+		],
+		"attributes": [{"name": "rack", "type": "TEXT", "text": {"value": "r1"}}],
+		"unknown": true
+	}`), offer)
+	require.Nil(t, err)
+	assert.Equal(t, "agent", offer.GetSlaveId().GetValue())
+	require.Len(t, offer.GetResources(), 2)
+	assert.Equal(t, 1.5, offer.GetResources()[0].GetScalar().GetValue())
+	assert.Equal(t, mesos.Value_RANGES, offer.GetResources()[1].GetType())
+	assert.Equal(t, uint64(32000), offer.GetResources()[1].GetRanges().GetRange()[0].GetEnd())
+	assert.Equal(t, "r1", offer.GetAttributes()[0].GetText().GetValue())
+
+	assert.NotNil(t, v1Unmarshal([]byte(`{"state": "TASK_UNHEARD_OF"}`), new(mesos.TaskStatus)))
+}
+
+func TestV1JSONEvents(t *testing.T) {
+	// as sent by a master, with enums such as volume modes, disk source types and IP protocols
+	offers := `{
+		"type": "OFFERS",
+		"offers": {"offers": [{
+			"id": {"value": "b3f3a7a6-0d1a-4c6e-9d3b-3f1f2d8c6a51-O12"},
+			"framework_id": {"value": "b3f3a7a6-0d1a-4c6e-9d3b-3f1f2d8c6a51-0001"},
+			"agent_id": {"value": "b3f3a7a6-0d1a-4c6e-9d3b-3f1f2d8c6a51-S0"},
+			"hostname": "agent1",
+			"url": {
+				"scheme": "http",
+				"address": {"hostname": "agent1", "ip": "10.0.0.2", "port": 5051},
+				"path": "/slave(1)"
+			},
+			"resources": [
+				{"name": "cpus", "type": "SCALAR", "scalar": {"value": 4.0}, "role": "*"},
+				{
+					"name": "disk", "type": "SCALAR", "scalar": {"value": 1024.0}, "role": "gonsumer",
+					"reservation": {"principal": "gonsumer"},
+					"disk": {
+						"persistence": {"id": "data", "principal": "gonsumer"},
+						"volume": {"container_path": "data", "mode": "RW"}
+					}
+				},
+				{
+					"name": "disk", "type": "SCALAR", "scalar": {"value": 10240.0}, "role": "*",
+					"disk": {"source": {"type": "MOUNT", "mount": {"root": "/mnt/disk1"}}}
+				},
+				{"name": "ports", "type": "RANGES", "ranges": {"range": [{"begin": 31000, "end": 32000}]}, "role": "*"}
+			]
+		}]}
+	}`
+
+	event := new(v1Event)
+	require.Nil(t, v1Unmarshal([]byte(offers), event))
+	require.Len(t, event.Offers.Offers, 1)
+	resources := event.Offers.Offers[0].GetResources()
+	require.Len(t, resources, 4)
+	assert.Equal(t, "data", resources[1].GetDisk().GetPersistence().GetId())
+	assert.Equal(t, mesos.Volume_RW, resources[1].GetDisk().GetVolume().GetMode())
+	assert.Equal(t, mesos.Resource_DiskInfo_Source_MOUNT, resources[2].GetDisk().GetSource().GetType())
+	assert.Equal(t, "/mnt/disk1", resources[2].GetDisk().GetSource().GetMount().GetRoot())
+
+	// resources are sent back as they were offered
+	data, err := json.Marshal(v1Marshal(resources[1]))
+	require.Nil(t, err)
+	assert.Contains(t, string(data), `"mode":"RW"`)
+
+	update := `{
+		"type": "UPDATE",
+		"update": {"status": {
+			"task_id": {"value": "gonsumer-group-0-1b4e28ba"},
+			"state": "TASK_RUNNING",
+			"source": "SOURCE_EXECUTOR",
+			"agent_id": {"value": "b3f3a7a6-0d1a-4c6e-9d3b-3f1f2d8c6a51-S0"},
+			"executor_id": {"value": "gonsumer-group-0-1b4e28ba"},
+			"timestamp": 1503329134.75,
+			"uuid": "dXVpZA==",
+			"container_status": {
+				"container_id": {"value": "7a1c2f3e"},
+				"network_infos": [{"ip_addresses": [{"protocol": "IPv4", "ip_address": "10.0.0.2"}]}]
+			}
+		}}
+	}`
+
+	event = new(v1Event)
+	require.Nil(t, v1Unmarshal([]byte(update), event))
+	status := event.Update.Status
+	assert.Equal(t, mesos.TaskState_TASK_RUNNING, status.GetState())
+	assert.Equal(t, mesos.TaskStatus_SOURCE_EXECUTOR, status.GetSource())
+	addresses := status.GetContainerStatus().GetNetworkInfos()[0].GetIpAddresses()
+	assert.Equal(t, mesos.NetworkInfo_IPv4, addresses[0].GetProtocol())
+	assert.Equal(t, "10.0.0.2", addresses[0].GetIpAddress())
+}